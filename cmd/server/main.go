@@ -1,195 +1,443 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math/rand"
-	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"net-centric-clash-royale/internal/handlers"
+	"net-centric-clash-royale/internal/matchmaking"
 	"net-centric-clash-royale/internal/models"
 	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+	"net-centric-clash-royale/internal/utils"
 )
 
-// playerQueueEntry holds information for a player waiting in a matchmaking queue.
-type playerQueueEntry struct {
-	player   *models.Player
-	conn     net.Conn
-	waitChan chan bool
-}
-
-var (
-	// Separate queues for timed and untimed games.
-	timedQueue   map[string]playerQueueEntry
-	untimedQueue map[string]playerQueueEntry
-	queueMutex   sync.Mutex
-
-	// Global mutex for player data and other shared resources.
-	globalPlayerMutex sync.Mutex
-)
+const serverName = "clash-royale-server"
 
-func init() {
-	timedQueue = make(map[string]playerQueueEntry)
-	untimedQueue = make(map[string]playerQueueEntry)
-}
+// serverFeatures là các feature flag server có thể bật khi handshake.
+var serverFeatures = []string{network.FeatureTimed, network.FeatureReconnect, network.FeatureRealtime, network.FeatureSpectate}
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
 	fmt.Println("🚀 Starting TCP Server on port 9000...")
 
-	players, err := handlers.LoadPlayers()
+	storeKind := flag.String("store", "file", "player storage backend: file or sqlite")
+	dbPath := flag.String("db", filepath.Join("data", "clash.db"), "SQLite database path (with -store=sqlite)")
+	rulesPath := flag.String("rules", filepath.Join("data", "rules.json"), "ruleset file")
+	levelsPath := flag.String("levels", filepath.Join("data", "levels.json"), "card and tower level curves file")
+	flag.StringVar(&handlers.ReplayDir, "replays", handlers.ReplayDir, "directory for match replay files")
+	flag.Parse()
+
+	rules, err := utils.LoadRuleset(*rulesPath)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("⚠️ %s not found, using default rules\n", *rulesPath)
+	} else if err != nil {
+		log.Fatalf("❌ Failed to load rules: %v", err)
+	}
+	if rules.Levels, err = utils.LoadLevelCurves(*levelsPath); err != nil {
+		log.Fatalf("❌ Failed to load level curves: %v", err)
+	}
+	if rules.Troops, err = utils.LoadTroopsFromFile("data/troop.json"); err != nil {
+		log.Fatalf("❌ Failed to load troops: %v", err)
+	}
+
+	store, err := openPlayerStore(*storeKind, *dbPath)
 	if err != nil {
 		log.Fatalf("❌ Failed to load players: %v", err)
 	}
+	tokens := handlers.NewTokenStore(handlers.SessionTokenTTL)
+
+	// Lưu tiến trình người chơi khi server tắt (Ctrl+C / SIGTERM)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		fmt.Println("🛑 Shutting down, saving player data...")
+		if err := store.Close(); err != nil {
+			fmt.Printf("❌ Failed to save player data: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
 
-	network.StartTCPServer("9000", func(conn net.Conn) {
-		handleConnectionWithPDU(conn, players)
+	// Trận đấu bắt đầu khi cả hai người chơi được ghép đã ngừng chờ.
+	// The game session will manage the connections and close them when the game ends.
+	matchmaker := matchmaking.New(func(host, guest *matchmaking.Ticket) {
+		gameOver := handlers.StartGameSession(host.Player, guest.Player, host.Conn, guest.Conn, host.Mode, store, rules)
+		<-gameOver
+		fmt.Printf("🏁 Game session between %s and %s ended.\n", host.Player.Username, guest.Player.Username)
+	})
+	go matchmaker.Run(nil)
+
+	network.StartTCPServer("9000", func(conn *network.Conn) {
+		handleConnectionWithPDU(conn, store, tokens, matchmaker, rules)
 	})
 }
 
-func handleConnectionWithPDU(conn net.Conn, playerMap map[string]*models.Player) {
+// openPlayerStore mở backend lưu trữ đã chọn. Lần đầu dùng SQLite, người chơi
+// trong data/players.json được chép sang database.
+func openPlayerStore(kind, dbPath string) (storage.PlayerStore, error) {
+	jsonPath := filepath.Join("data", "players.json")
+	switch kind {
+	case "file":
+		return storage.NewFileStore(jsonPath)
+	case "sqlite":
+		db, err := storage.NewSQLStore(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(db.List()) == 0 {
+			legacy, err := storage.NewFileStore(jsonPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range legacy.List() {
+				if err := db.Put(p); err != nil {
+					return nil, fmt.Errorf("import %s: %w", p.Username, err)
+				}
+			}
+			fmt.Printf("🗄️ Imported %d players from %s\n", len(legacy.List()), jsonPath)
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown store %q", kind)
+	}
+}
 
-	// Authenticate user (register/login)
-	player := handlers.Authenticate(conn, &playerMap, &globalPlayerMutex)
-	if player == nil {
+func handleConnectionWithPDU(conn *network.Conn, store storage.PlayerStore, tokens *handlers.TokenStore, matchmaker *matchmaking.Matchmaker, rules models.Ruleset) {
+	// Handshake: thoả thuận version, codec và feature trước khi đăng nhập
+	hello, err := conn.AcceptHandshake(serverName, serverFeatures)
+	if err != nil {
+		fmt.Printf("❌ Handshake with %s failed: %v\n", conn.RemoteAddr(), err)
 		conn.Close()
 		return
 	}
+	if hello != nil {
+		fmt.Printf("🤝 %s connected with %s %s (codec %s)\n", conn.RemoteAddr(), hello.ClientName, hello.ClientVersion, conn.Codec().Name())
+	} else {
+		fmt.Printf("🤝 %s connected as a legacy text client\n", conn.RemoteAddr())
+	}
+
+	// Client có session token hợp lệ thì bỏ qua bước đăng nhập
+	var player *models.Player
+	if hello != nil && hello.SessionToken != "" {
+		player = handlers.ResumeSession(conn, hello.SessionToken, store, tokens)
+	}
+	if player == nil {
+		// Authenticate user (register/login)
+		player = handlers.Authenticate(conn, store)
+		if player == nil {
+			conn.Close()
+			return
+		}
+		handlers.IssueSessionToken(conn, tokens, player.Username)
+	}
 
-	network.SendPDU(conn, "info", fmt.Sprintf("Welcome, %s!", player.Username))
+	// Người chơi đang có trận dở dang thì quay lại trận đó
+	if handlers.HasActiveSession(player.Username) {
+		if !conn.Supports(network.FeatureReconnect) {
+			conn.SendPDU("error", "❌ You have a match in progress and your client does not support reconnecting. Please try again later.")
+			conn.Close()
+			return
+		}
+		if handlers.ReconnectPlayer(player.Username, conn) {
+			return
+		}
+	}
+
+	conn.SendPDU("info", fmt.Sprintf("Welcome, %s!", player.Username))
+	conn.SendPDU("info", handlers.DeckHelp)
+	conn.SendPDU("info", handlers.UpgradeHelp)
+	conn.SendPDU("info", handlers.PrivateHelp)
+	conn.SendPDU("info", handlers.SpectateHelp)
+	conn.SendPDU("info", handlers.ReplayHelp)
+	conn.SendPDU("info", handlers.HistoryHelp)
 
 	for {
 		// --- Game Mode Selection Logic (re-integrated) ---
-		var isTimedGame bool
+		var mode models.GameMode
 		for {
-			network.SendPDU(conn, "menu", "Choose game mode:\n1. Timed Game (3 minutes)\n2. Untimed Game (play following turn)\nEnter 1 or 2:")
-			pdu, err := network.ReadPDU(conn)
+			conn.SendPDU("menu", handlers.LobbyMenu(rules))
+			pdu, err := conn.ReadPDU()
 			if err != nil {
 				fmt.Println("❌ Failed to read PDU for game mode selection:", err)
 				return
 			}
-			choice := strings.TrimSpace(pdu.Payload)
-
-			switch choice {
-			case "1":
-				isTimedGame = true
-				network.SendPDU(conn, "info", "You selected: Timed Game (3 minutes)")
-				break
-			case "2":
-				isTimedGame = false
-				network.SendPDU(conn, "info", "You selected: Untimed Game (play following turn)")
-				break
-			default:
-				network.SendPDU(conn, "error", "❗ Invalid choice. Please enter 1 or 2.")
+			if pdu.Type == network.MsgLogout {
+				tokens.RevokeUser(player.Username)
+				conn.SendPDU("info", "👋 Logged out.")
+				conn.Close()
+				return
+			}
+			if handlers.HandleDeckCommand(conn, pdu, player, store, rules) || handlers.HandleUpgradeCommand(conn, pdu, player, store, rules) ||
+				handlers.HandleMatchesCommand(conn, pdu) || handlers.HandleHistoryCommand(conn, pdu, player, store) {
+				continue
+			}
+			if handled, err := handlers.HandleReplayCommand(conn, pdu, player); handled {
+				if err != nil {
+					fmt.Printf("❌ %s disconnected while watching a replay: %v\n", player.Username, err)
+					return
+				}
 				continue
 			}
+			if target, ok := handlers.ParseSpectateRequest(pdu); ok {
+				if !conn.Supports(network.FeatureSpectate) {
+					conn.Reply(pdu, "error", "❗ Your client does not support spectating.", nil)
+					continue
+				}
+				if target == "" {
+					conn.Reply(pdu, "error", "❗ Usage: spectate <player>", nil)
+					continue
+				}
+				if err := handlers.Spectate(conn, player.Username, target); errors.Is(err, handlers.ErrMatchNotFound) {
+					conn.Reply(pdu, "error", "❌ "+target+" is not playing right now.", nil)
+				} else if err != nil {
+					fmt.Printf("❌ %s disconnected while spectating: %v\n", player.Username, err)
+					return
+				}
+				continue
+			}
+			if privateMode, privateRules, ok, err := handlers.ParsePrivateRequest(pdu, rules); ok {
+				if err != nil {
+					conn.Reply(pdu, "error", "❌ Invalid private match: "+err.Error()+".", nil)
+					continue
+				}
+				if !handlers.ModeSupported(conn, privateMode) {
+					conn.Reply(pdu, "error", "❗ Your client does not support this game mode.", nil)
+					continue
+				}
+				started, err := hostPrivateMatch(conn, pdu, player, privateMode, privateRules)
+				if err != nil {
+					fmt.Printf("❌ %s disconnected while hosting a private match: %v\n", player.Username, err)
+					return
+				}
+				if started {
+					return
+				}
+				continue
+			}
+			if code, ok, err := handlers.ParseJoinRequest(pdu); ok {
+				if err != nil {
+					conn.Reply(pdu, "error", "❌ Invalid join request.", nil)
+					continue
+				}
+				if joinPrivateMatch(conn, pdu, player, code, store) {
+					return
+				}
+				continue
+			}
+			if level, ok, err := handlers.ParseBotRequest(conn, pdu); ok {
+				if errors.Is(err, handlers.ErrInvalidBotLevel) {
+					conn.Reply(pdu, "error", "❗ Invalid difficulty. Please enter 1, 2 or 3.", nil)
+					continue
+				}
+				if err != nil {
+					fmt.Printf("❌ %s disconnected while choosing bot difficulty: %v\n", player.Username, err)
+					return
+				}
+				handlers.StartBotGame(player, conn, level, store, rules)
+				// Do not read from conn here: the game session owns it from now on.
+				return
+			}
+			var ok bool
+			mode, ok = models.ParseModeChoice(pdu.Payload)
+			if !ok {
+				conn.SendPDU("error", "❗ Invalid choice. Please enter 1, 2, 3 or 4.")
+				continue
+			}
+
+			switch mode {
+			case models.ModeTimed:
+				if !handlers.ModeSupported(conn, mode) {
+					conn.SendPDU("error", "❗ Your client does not support Timed Game. Please enter 2.")
+					continue
+				}
+			case models.ModeRealtime:
+				if !handlers.ModeSupported(conn, mode) {
+					conn.SendPDU("error", "❗ Your client does not support Real-time Battle. Please enter 1 or 2.")
+					continue
+				}
+			}
+			conn.SendPDU("info", "You selected: "+mode.Title(rules))
 			break
 		}
 		// --- End Game Mode Selection Logic ---
 
-		var currentQueue map[string]playerQueueEntry
-
-		if isTimedGame {
-			currentQueue = timedQueue
-		} else {
-			currentQueue = untimedQueue
+		started, err := findMatch(conn, matchmaker, player, mode, rules)
+		if err != nil {
+			fmt.Printf("❌ %s disconnected while searching for a match: %v\n", player.Username, err)
+			return
 		}
-
-		// --- Matchmaking Logic (re-integrated) ---
-		queueMutex.Lock() // Lock for queue access
-
-		var opponentEntry playerQueueEntry
-		var foundOpponent bool
-
-		// Check if there's a waiting player in the chosen queue
-		if len(currentQueue) > 0 {
-			for _, entry := range currentQueue {
-				opponentEntry = entry
-				foundOpponent = true
-				break
-			}
+		if started {
+			// Do not read from conn here: the game session owns it from now on.
+			return
 		}
+	}
+}
 
-		if !foundOpponent {
-			waitChan := make(chan bool)
-			entry := playerQueueEntry{
-				player:   player,
-				conn:     conn,
-				waitChan: waitChan,
-			}
-			currentQueue[player.Username] = entry
-			queueMutex.Unlock()
+// findMatch đưa người chơi vào hàng chờ của mode và xử lý lệnh của client
+// trong lúc chờ ('cancel', 'status'). Trả về true nếu trận đấu đã bắt đầu và
+// sở hữu conn; trả về lỗi nếu client ngắt kết nối.
+func findMatch(conn *network.Conn, matchmaker *matchmaking.Matchmaker, player *models.Player, mode models.GameMode, rules models.Ruleset) (bool, error) {
+	ticket, err := matchmaker.Enqueue(player, conn, mode)
+	if err != nil {
+		conn.SendPDU("error", fmt.Sprintf("❌ Cannot join the queue: %v.", err))
+		return false, nil
+	}
+	conn.SendPDU("info", fmt.Sprintf("Waiting for an opponent near rating %d. Type 'cancel' to leave the queue or 'status' to see your position (timeout in %ds)...",
+		ticket.Rating, rules.MatchmakingTimeoutSec))
 
-			network.SendPDU(conn, "info", "Waiting for an opponent to join. Please wait (timeout in 30s)...")
+	// Đọc conn trong goroutine riêng để phát hiện client ngắt kết nối hoặc huỷ tìm trận
+	inputs, stopReading := conn.ReadInBackground()
+	handOver := func(opponent *matchmaking.Ticket) (bool, error) {
+		stopReading()
+		conn.SendPDU("info", fmt.Sprintf("Opponent found: %s (rating %d)! Starting game...", opponent.Player.Username, opponent.Rating))
+		ticket.Release()
+		return true, nil
+	}
 
-			select {
-			case matchFound := <-waitChan:
-				if !matchFound {
-					network.SendPDU(conn, "info", "❌ Matchmaking timed out. No opponent found. Please choose game mode again.")
-					continue
+	timeout := time.After(rules.MatchmakingTimeout())
+	for {
+		select {
+		case opponent := <-ticket.Matched:
+			return handOver(opponent)
+		case in := <-inputs:
+			if in.Err != nil {
+				if matchmaker.Cancel(ticket) {
+					stopReading()
+					return false, in.Err
 				}
-
-				network.SendPDU(conn, "info", "Opponent found! Starting game...")
-				_, err := network.ReadPDU(conn)
-				if err != nil {
-					fmt.Printf("DEBUG: Player %s's connection closed after game started: %v\n", player.Username, err)
+				// Đã được ghép cặp: trận đấu sẽ xử lý việc mất kết nối
+				return handOver(<-ticket.Matched)
+			}
+			switch {
+			case isQueueCommand(in.PDU, network.MsgCancelQueue, "cancel"):
+				if matchmaker.Cancel(ticket) {
+					stopReading()
+					conn.Reply(in.PDU, "info", "👋 You left the matchmaking queue. Please choose game mode again.", nil)
+					return false, nil
 				}
-				return
-
-			case <-time.After(30 * time.Second):
-				// Timeout occurred
-				queueMutex.Lock()
-				if _, ok := currentQueue[player.Username]; ok {
-					delete(currentQueue, player.Username)
+				// Được ghép cặp đúng lúc huỷ: đối thủ đang chờ trong ticket.Matched
+			case isQueueCommand(in.PDU, network.MsgQueueStatus, "status"):
+				if status, ok := matchmaker.Status(ticket); ok {
+					text, data := matchmaking.Describe(ticket, status)
+					conn.Reply(in.PDU, network.MsgQueueStatus, text, data)
 				}
-				queueMutex.Unlock()
-				network.SendPDU(conn, "info", "❌ Matchmaking timed out. No opponent found. Please choose game mode again.")
+			default:
+				conn.Reply(in.PDU, "error", "❗ Still searching for an opponent. Type 'cancel' to leave the queue.", nil)
+			}
+		case <-ticket.Notify:
+			// Status kiểm tra lại dưới lock: ticket vừa được ghép cặp thì không gửi
+			status, ok := matchmaker.Status(ticket)
+			if !ok {
 				continue
 			}
-
-		} else {
-			// Opponent found in this mode's queue, initiate game
-			delete(currentQueue, opponentEntry.player.Username)
-			queueMutex.Unlock()
-
-			// Signal the waiting player that a match has been found
-			if opponentEntry.waitChan != nil {
-				opponentEntry.waitChan <- true
-				close(opponentEntry.waitChan)
+			text, data := matchmaking.Describe(ticket, status)
+			if err := conn.Send(network.MsgQueueStatus, text, data); err != nil && matchmaker.Cancel(ticket) {
+				fmt.Printf("❌ Dropping %s from matchmaking: %v\n", player.Username, err)
+				stopReading()
+				return false, err
 			}
+		case <-timeout:
+			if matchmaker.Cancel(ticket) {
+				stopReading()
+				conn.SendPDU("info", "❌ Matchmaking timed out. No opponent found. Please choose game mode again.")
+				return false, nil
+			}
+		}
+	}
+}
 
-			network.SendPDU(conn, "info", "Opponent found! Starting game...")
+// isQueueCommand reports whether pdu is the typed request pduType or the text command word.
+func isQueueCommand(pdu network.PDU, pduType, word string) bool {
+	return pdu.Type == pduType || strings.EqualFold(strings.TrimSpace(pdu.Payload), word)
+}
 
-			// Start mana regeneration and game session in a new goroutine
-			// The game session will manage the connections and close them when the game ends.
-			go func(p1, p2 *models.Player, c1, c2 net.Conn, timedGame bool) {
-				playersArr := []*models.Player{p1, p2}
-				handlers.StartManaRegeneration(playersArr, &globalPlayerMutex)
+// hostPrivateMatch tạo trận riêng, gửi mã mời và chờ người chơi thứ hai.
+// Trong lúc chờ, chủ phòng có thể gõ 'cancel' để huỷ mã mời. Trả về true nếu
+// trận đấu đã bắt đầu và sở hữu conn; trả về lỗi nếu client ngắt kết nối.
+func hostPrivateMatch(conn *network.Conn, req network.PDU, player *models.Player, mode models.GameMode, rules models.Ruleset) (bool, error) {
+	match, err := handlers.CreatePrivateMatch(player, conn, mode, rules)
+	if err != nil {
+		fmt.Printf("❌ Failed to create private match for %s: %v\n", player.Username, err)
+		conn.Reply(req, "error", "❌ Failed to create private match.", nil)
+		return false, nil
+	}
+	ttl := int(handlers.PrivateMatchTTL.Seconds())
+	conn.Reply(req, network.MsgPrivateCreated,
+		fmt.Sprintf("🔒 Private %s match created. Invite code: %s (expires in %ds). Type 'cancel' to cancel it.", mode, match.Code, ttl),
+		network.PrivateCreated{Code: match.Code, Mode: mode.String(), ExpiresInSec: ttl})
 
-				// Start the game session and get the game over channel
-				gameOver := handlers.StartGameSession(p1, p2, c1, c2, timedGame)
-				<-gameOver
-				fmt.Printf("DEBUG: Game session between %s and %s ended.\n", p1.Username, p2.Username)
-				// Connections are closed by GameSession when it signals completion.
-			}(opponentEntry.player, player, opponentEntry.conn, conn, isTimedGame)
+	// Đọc conn trong goroutine riêng để phát hiện client ngắt kết nối hoặc huỷ trận
+	inputs, stopReading := conn.ReadInBackground()
+	handOver := func() (bool, error) {
+		<-match.Joined
+		stopReading()
+		close(match.Released)
+		return true, nil
+	}
 
-			_, err := network.ReadPDU(conn)
-			if err != nil {
-				fmt.Printf("DEBUG: Player %s's connection closed after game started: %v\n", player.Username, err)
+	timeout := time.After(handlers.PrivateMatchTTL)
+	for {
+		select {
+		case <-match.Joined:
+			return handOver()
+		case in := <-inputs:
+			if in.Err != nil {
+				if handlers.CancelPrivateMatch(match) {
+					stopReading()
+					return false, in.Err
+				}
+				// Đã có người vào trận: trận đấu sẽ xử lý việc mất kết nối
+				return handOver()
 			}
-			return
+			if isQueueCommand(in.PDU, network.MsgCancelQueue, "cancel") {
+				if handlers.CancelPrivateMatch(match) {
+					stopReading()
+					conn.Reply(in.PDU, "info", "👋 Private match cancelled. Please choose game mode again.", nil)
+					return false, nil
+				}
+				// Có người vừa nhập mã đúng lúc huỷ
+				return handOver()
+			}
+			conn.Reply(in.PDU, "error", fmt.Sprintf("❗ Waiting for someone to join with code %s. Type 'cancel' to cancel.", match.Code), nil)
+		case <-timeout:
+			if handlers.CancelPrivateMatch(match) {
+				stopReading()
+				conn.SendPDU("info", "❌ Nobody joined your private match. Please choose game mode again.")
+				return false, nil
+			}
+			// Có người vừa nhập mã đúng lúc hết hạn
+			return handOver()
 		}
 	}
 }
 
-// sendInfoPDU sends a basic info message to the client
-func sendInfoPDU(conn net.Conn, message string) {
-	pdu := network.PDU{Type: "info", Payload: message}
-	data, err := network.EncodePDU(pdu)
-	if err == nil {
-		conn.Write(append(data, '\n'))
+// joinPrivateMatch vào trận riêng có mã code. Trả về true nếu trận đấu đã
+// bắt đầu và sở hữu conn.
+func joinPrivateMatch(conn *network.Conn, req network.PDU, player *models.Player, code string, store storage.PlayerStore) bool {
+	match, err := handlers.JoinPrivateMatch(code, player.Username, func(mode models.GameMode) bool {
+		return handlers.ModeSupported(conn, mode)
+	})
+	if err != nil {
+		conn.Reply(req, "error", "❌ Cannot join: "+err.Error()+".", nil)
+		return false
 	}
+	conn.SendPDU("info", fmt.Sprintf("Joined %s's private match! Starting game...", match.Host.Username))
+	match.Conn.SendPDU("info", fmt.Sprintf("%s joined your private match! Starting game...", player.Username))
+
+	close(match.Joined)
+	go func() {
+		// Chờ chủ phòng ngừng đọc kết nối của họ
+		<-match.Released
+		handlers.StartGameSession(match.Host, player, match.Conn, conn, match.Mode, store, match.Rules)
+	}()
+	return true
 }
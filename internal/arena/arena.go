@@ -0,0 +1,207 @@
+// Package arena mô tả sân đấu: hai lane nối King Tower của hai người chơi,
+// mỗi lane có một Guard Tower của mỗi bên. Mọi vị trí là toạ độ dọc theo
+// lane, từ 0 (King Tower của Home) tới Length (King Tower của Away).
+package arena
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	Length     = 24.0 // khoảng cách giữa hai King Tower
+	GuardPos   = 5.0  // khoảng cách từ King Tower tới Guard Tower cùng phía
+	GuardRange = 6.0
+	KingRange  = 5.0
+	MeleeRange = 1.0 // troop phải đứng cách mục tiêu bấy nhiêu để đánh
+
+	// DefaultSpeed dùng cho troop không khai báo speed trong troop.json (ô/giây).
+	DefaultSpeed = 1.0
+)
+
+// Lane là một trong hai đường đi của sân.
+type Lane int
+
+const (
+	Left Lane = iota
+	Right
+)
+
+func (l Lane) String() string {
+	if l == Right {
+		return "right"
+	}
+	return "left"
+}
+
+// ParseLane đọc "l", "left", "1", "r", "right" hoặc "2" (không phân biệt hoa thường).
+func ParseLane(s string) (Lane, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "l", "left", "1":
+		return Left, true
+	case "r", "right", "2":
+		return Right, true
+	}
+	return Left, false
+}
+
+// Side là phía sân của một người chơi.
+type Side int
+
+const (
+	Home Side = iota // Player1, King Tower ở vị trí 0
+	Away             // Player2, King Tower ở vị trí Length
+)
+
+// Opponent returns the other side.
+func (s Side) Opponent() Side {
+	return 1 - s
+}
+
+// Forward là hướng troop của side di chuyển: +1 với Home, -1 với Away.
+func (s Side) Forward() float64 {
+	if s == Away {
+		return -1
+	}
+	return 1
+}
+
+// abs chuyển khoảng cách tính từ King Tower của side thành toạ độ tuyệt đối.
+func (s Side) abs(d float64) float64 {
+	if s == Away {
+		return Length - d
+	}
+	return d
+}
+
+// Building là vị trí của một tower trên sân.
+type Building struct {
+	Index int     // vị trí trong Player.Towers
+	King  bool    // King Tower chắn cả hai lane
+	Lane  Lane    // lane của Guard Tower
+	Pos   float64 // toạ độ tuyệt đối
+	Range float64
+}
+
+// InLane reports whether the building stands in (or blocks) lane.
+func (b Building) InLane(l Lane) bool {
+	return b.King || b.Lane == l
+}
+
+// Covers reports whether a troop at pos in lane is within the building's range.
+func (b Building) Covers(l Lane, pos float64) bool {
+	return b.InLane(l) && math.Abs(pos-b.Pos) <= b.Range
+}
+
+// Arena giữ vị trí tower của cả hai bên trong một trận.
+type Arena struct {
+	buildings [2][]Building
+}
+
+// New places each side's towers given their types in Player.Towers order:
+// the King Tower at the back, Guard Towers on the left then right lane.
+func New(home, away []string) *Arena {
+	a := &Arena{}
+	for side, types := range [][]string{home, away} {
+		s := Side(side)
+		guards := 0
+		for i, t := range types {
+			if t == "King Tower" {
+				a.buildings[s] = append(a.buildings[s], Building{Index: i, King: true, Pos: s.abs(0), Range: KingRange})
+				continue
+			}
+			a.buildings[s] = append(a.buildings[s], Building{Index: i, Lane: Lane(guards % 2), Pos: s.abs(GuardPos), Range: GuardRange})
+			guards++
+		}
+	}
+	return a
+}
+
+// Buildings returns the buildings of side.
+func (a *Arena) Buildings(side Side) []Building {
+	return a.buildings[side]
+}
+
+// Building returns the building for tower index of side.
+func (a *Arena) Building(side Side, index int) (Building, bool) {
+	for _, b := range a.buildings[side] {
+		if b.Index == index {
+			return b, true
+		}
+	}
+	return Building{}, false
+}
+
+// SpawnPos là vị trí troop của side xuất hiện: ngay trước Guard Tower của mình.
+func SpawnPos(side Side) float64 {
+	return side.abs(GuardPos + MeleeRange)
+}
+
+// FightPos là vị trí troop của attacker đứng để đánh building b.
+func FightPos(attacker Side, b Building) float64 {
+	return b.Pos - attacker.Forward()*MeleeRange
+}
+
+// FirstBuilding returns the first living enemy building a troop from attacker
+// meets walking down lane. alive reports whether a defender tower still stands.
+func (a *Arena) FirstBuilding(attacker Side, lane Lane, alive func(index int) bool) (Building, bool) {
+	var first Building
+	found := false
+	for _, b := range a.buildings[attacker.Opponent()] {
+		if !b.InLane(lane) || !alive(b.Index) {
+			continue
+		}
+		// Building gần phía attacker hơn thì gặp trước
+		if !found || attacker.Forward()*(b.Pos-first.Pos) < 0 {
+			first, found = b, true
+		}
+	}
+	return first, found
+}
+
+// Reachable returns the defender tower indices a troop from attacker can reach
+// in some lane. The King Tower becomes reachable once a lane's Guard Tower falls.
+func (a *Arena) Reachable(attacker Side, alive func(index int) bool) []int {
+	seen := make(map[int]bool)
+	var indices []int
+	for _, lane := range []Lane{Left, Right} {
+		if b, ok := a.FirstBuilding(attacker, lane, alive); ok && !seen[b.Index] {
+			seen[b.Index] = true
+			indices = append(indices, b.Index)
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// LaneTo returns a lane through which attacker reaches the defender tower index.
+func (a *Arena) LaneTo(attacker Side, index int, alive func(index int) bool) (Lane, bool) {
+	for _, lane := range []Lane{Left, Right} {
+		if b, ok := a.FirstBuilding(attacker, lane, alive); ok && b.Index == index {
+			return lane, true
+		}
+	}
+	return Left, false
+}
+
+// Covering returns the living towers of defender whose range covers a troop
+// standing at pos in lane.
+func (a *Arena) Covering(defender Side, lane Lane, pos float64, alive func(index int) bool) []int {
+	var indices []int
+	for _, b := range a.buildings[defender] {
+		if alive(b.Index) && b.Covers(lane, pos) {
+			indices = append(indices, b.Index)
+		}
+	}
+	return indices
+}
+
+// Advance moves a troop of side from pos by dist, without passing limit.
+func Advance(side Side, pos, dist, limit float64) float64 {
+	next := pos + side.Forward()*dist
+	if side.Forward()*(next-limit) > 0 {
+		return limit
+	}
+	return next
+}
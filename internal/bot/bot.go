@@ -0,0 +1,129 @@
+// Package bot chọn nước đi cho đối thủ do server điều khiển trong trận theo
+// lượt. Bot chỉ dựa vào luật trong package engine: nó thử từng hành động hợp
+// lệ trên bản sao của trạng thái trận và chọn hành động có điểm cao nhất.
+package bot
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	"net-centric-clash-royale/internal/engine"
+)
+
+// Level là độ khó của bot.
+type Level int
+
+const (
+	Easy   Level = iota // chọn ngẫu nhiên trong các hành động hợp lệ
+	Normal              // chọn hành động tốt nhất sau một lần thử, đôi khi chọn sai
+	Hard                // chọn hành động tốt nhất sau nhiều lần thử
+)
+
+// Trọng số khi chấm điểm một trạng thái trận.
+const (
+	winScore   = 1e6 // thắng hoặc thua cả trận
+	towerScore = 500 // mỗi tower bị phá
+	critValue  = 100 // mỗi CRIT còn lại; bot chỉ dùng CRIT khi đáng giá hơn
+	manaValue  = 15  // mỗi điểm mana còn lại
+	// normalMistakes là tỉ lệ bot Normal chọn ngẫu nhiên thay vì chọn nước tốt nhất.
+	normalMistakes = 0.2
+)
+
+func (l Level) String() string {
+	switch l {
+	case Easy:
+		return "easy"
+	case Hard:
+		return "hard"
+	default:
+		return "normal"
+	}
+}
+
+// ParseLevel nhận tên độ khó ("easy", "normal", "hard") hoặc lựa chọn trong
+// menu ("1", "2", "3").
+func ParseLevel(s string) (Level, bool) {
+	s = strings.TrimSpace(s)
+	for i, l := range []Level{Easy, Normal, Hard} {
+		if strings.EqualFold(s, l.String()) || s == string(rune('1'+i)) {
+			return l, true
+		}
+	}
+	return Normal, false
+}
+
+// samples là số lần mô phỏng mỗi hành động. Mỗi lần dùng RNG riêng của bot
+// thay cho RNG của trận để bot không biết trước CRIT của tower.
+func (l Level) samples() int {
+	if l == Hard {
+		return 12
+	}
+	return 1
+}
+
+// Choose chọn hành động cho người chơi đang có lượt trong s. Bot không bao giờ
+// đầu hàng; Pass luôn hợp lệ nên luôn có hành động để chọn.
+func Choose(s engine.State, level Level, rng *rand.Rand) engine.Action {
+	var candidates []engine.Action
+	for _, a := range engine.LegalActions(s) {
+		if _, ok := a.(engine.Surrender); !ok {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		return engine.Pass{Player: s.Turn}
+	}
+	if level == Easy || (level == Normal && rng.Float64() < normalMistakes) {
+		return candidates[rng.Intn(len(candidates))]
+	}
+
+	best, bestScore := candidates[0], math.Inf(-1)
+	for _, a := range candidates {
+		score := 0.0
+		for i := 0; i < level.samples(); i++ {
+			trial := s
+			trial.RNG = rng.Uint64()
+			next, _, err := engine.Apply(trial, a)
+			if err != nil {
+				score = math.Inf(-1)
+				break
+			}
+			score += evaluate(next, s.Turn)
+		}
+		if score > bestScore {
+			best, bestScore = a, score
+		}
+	}
+	return best
+}
+
+// evaluate chấm điểm trạng thái s theo góc nhìn của người chơi me.
+func evaluate(s engine.State, me int) float64 {
+	if s.Over {
+		switch s.Winner {
+		case me:
+			return winScore
+		case -1:
+			return 0
+		}
+		return -winScore
+	}
+	score := 0.0
+	for _, t := range s.Players[engine.Opponent(me)].Towers {
+		if t.HP > 0 {
+			score -= float64(t.HP)
+		} else {
+			score += towerScore
+		}
+	}
+	mine := s.Players[me]
+	for _, t := range mine.Towers {
+		if t.HP > 0 {
+			score += float64(t.HP)
+		} else {
+			score -= towerScore
+		}
+	}
+	return score + critValue*float64(mine.CritsLeft) + manaValue*float64(mine.Mana)
+}
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// ErrUnknownTroop là lỗi khi ability spawn nhắc tới troop không có trong
+// rules.Troops.
+var ErrUnknownTroop = errors.New("unknown troop")
+
+// Ability là hành vi đặc biệt của troop. Troop chọn ability bằng trường
+// "special" trong troop.json và cấu hình chúng bằng "params". Mọi hook đều
+// có thể nil.
+type Ability struct {
+	// Cast dùng troop ngay khi được chọn thay vì cho ra trận (vd. heal).
+	// Trả về lỗi nếu không dùng được; khi đó troop không bị tiêu hao.
+	Cast func(s *State, player int, troop models.Troop) ([]Event, error)
+	// Spawn khởi tạo hiệu ứng của troop khi ra trận (vd. shield).
+	Spawn func(troop models.Troop, fx *Effects)
+	// Hit chạy mỗi khi troop đánh trúng, trước khi damage được áp dụng.
+	Hit func(h *Hit)
+	// Death trả về các troop (lấy từ rules.Troops) xuất hiện tại chỗ khi
+	// troop chết.
+	Death func(rules models.Ruleset, troop models.Troop) ([]models.Troop, error)
+	// TowersOnly cho troop bỏ qua troop đối phương và chỉ đánh tower.
+	TowersOnly bool
+}
+
+// abilities là registry ability theo tên dùng trong trường "special".
+var abilities = make(map[string]*Ability)
+
+// RegisterAbility adds (or replaces) the ability used by troops whose
+// "special" field names it.
+func RegisterAbility(name string, a *Ability) {
+	abilities[strings.ToLower(name)] = a
+}
+
+func init() {
+	RegisterAbility("heal", &Ability{Cast: castHeal})
+	RegisterAbility("splash", &Ability{Hit: func(h *Hit) {
+		if h.Splash != nil {
+			h.Splash(int(float64(h.Damage)*Param(h.Troop, "ratio", 0.5)), Param(h.Troop, "radius", 1.5))
+		}
+	}})
+	RegisterAbility("shield", &Ability{Spawn: func(troop models.Troop, fx *Effects) {
+		fx.Shield = int(Param(troop, "shield", 100))
+	}})
+	RegisterAbility("stun", &Ability{Hit: func(h *Hit) {
+		if d := int(Param(h.Troop, "duration", 1)); d > h.Target.Stun {
+			h.Target.Stun = d
+			h.Effects = append(h.Effects, "stun")
+		}
+	}})
+	RegisterAbility("dot", &Ability{Hit: func(h *Hit) {
+		h.Target.dots = append(h.Target.dots, dot{
+			source: h.Troop.Name,
+			damage: int(Param(h.Troop, "damage", 20)),
+			left:   int(Param(h.Troop, "duration", 3)),
+		})
+		h.Effects = append(h.Effects, "dot")
+	}})
+	RegisterAbility("charge", &Ability{Hit: func(h *Hit) {
+		if h.First && h.Traveled >= Param(h.Troop, "distance", 3) {
+			h.Damage = int(float64(h.Damage) * Param(h.Troop, "multiplier", 2))
+			h.Effects = append(h.Effects, "charge")
+		}
+	}})
+	RegisterAbility("spawn", &Ability{Death: spawnOnDeath})
+	RegisterAbility("tower_only", &Ability{TowersOnly: true})
+}
+
+// abilitiesOf trả về các ability đã đăng ký của troop; tên không rõ bị bỏ qua.
+func abilitiesOf(troop models.Troop) []*Ability {
+	var list []*Ability
+	for _, name := range strings.Split(troop.Special, ",") {
+		if a, ok := abilities[strings.ToLower(strings.TrimSpace(name))]; ok {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// castAbility trả về ability dùng ngay của troop (nếu có).
+func castAbility(troop models.Troop) *Ability {
+	for _, a := range abilitiesOf(troop) {
+		if a.Cast != nil {
+			return a
+		}
+	}
+	return nil
+}
+
+// IsCast cho biết troop được dùng ngay (Heal, Cast) thay vì ra trận.
+func IsCast(troop models.Troop) bool {
+	return castAbility(troop) != nil
+}
+
+// TowersOnly cho biết troop bỏ qua troop đối phương và chỉ đánh tower.
+func TowersOnly(troop models.Troop) bool {
+	for _, a := range abilitiesOf(troop) {
+		if a.TowersOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// SpawnEffects khởi tạo hiệu ứng cho troop vừa ra trận.
+func SpawnEffects(troop models.Troop) Effects {
+	var fx Effects
+	for _, a := range abilitiesOf(troop) {
+		if a.Spawn != nil {
+			a.Spawn(troop, &fx)
+		}
+	}
+	return fx
+}
+
+// DeathSpawns trả về các troop xuất hiện khi troop chết.
+func DeathSpawns(rules models.Ruleset, troop models.Troop) ([]models.Troop, error) {
+	var spawned []models.Troop
+	for _, a := range abilitiesOf(troop) {
+		if a.Death != nil {
+			troops, err := a.Death(rules, troop)
+			if err != nil {
+				return nil, err
+			}
+			spawned = append(spawned, troops...)
+		}
+	}
+	return spawned, nil
+}
+
+// Param đọc tham số số của ability, trả về def nếu thiếu hoặc sai kiểu.
+func Param(troop models.Troop, key string, def float64) float64 {
+	if v, ok := troop.Params[key].(float64); ok {
+		return v
+	}
+	return def
+}
+
+func paramString(troop models.Troop, key, def string) string {
+	if v, ok := troop.Params[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Effects là các hiệu ứng đang tác động lên một troop hoặc tower. Thời gian
+// tính theo giây ở chế độ real-time và theo hiệp ở chế độ theo lượt.
+type Effects struct {
+	Shield int // damage còn chặn được
+	Stun   int // số giây / hiệp còn bị choáng
+	dots   []dot
+}
+
+type dot struct {
+	source string
+	damage int
+	left   int
+}
+
+// Absorb trừ damage vào shield và trả về phần còn lại.
+func (fx *Effects) Absorb(damage int) int {
+	if fx.Shield <= 0 {
+		return damage
+	}
+	if damage <= fx.Shield {
+		fx.Shield -= damage
+		return 0
+	}
+	damage -= fx.Shield
+	fx.Shield = 0
+	return damage
+}
+
+// Tick giảm thời gian choáng và trả về tổng damage theo thời gian trong giây / hiệp này.
+func (fx *Effects) Tick() int {
+	if fx.Stun > 0 {
+		fx.Stun--
+	}
+	total := 0
+	active := fx.dots[:0]
+	for _, d := range fx.dots {
+		total += d.damage
+		d.left--
+		if d.left > 0 {
+			active = append(active, d)
+		}
+	}
+	fx.dots = active
+	return total
+}
+
+// DotSource là tên troop gây damage theo thời gian, dùng cho combat log.
+func (fx *Effects) DotSource() string {
+	if len(fx.dots) > 0 {
+		return fx.dots[0].source
+	}
+	return "poison"
+}
+
+// Hit là một đòn đánh của troop, được các ability chỉnh sửa trước khi áp dụng.
+type Hit struct {
+	Troop    models.Troop
+	Damage   int
+	First    bool    // đòn đầu tiên của troop
+	Traveled float64 // quãng đường troop đã đi trước đòn này
+	Target   *Effects
+	// Splash gây damage cho các mục tiêu khác trong bán kính radius quanh mục tiêu chính
+	Splash  func(damage int, radius float64)
+	Effects []string
+}
+
+// NewHit tạo đòn đánh của troop và chạy các hook Hit.
+func NewHit(troop models.Troop, damage int, first bool, traveled float64, target *Effects, splash func(int, float64)) *Hit {
+	h := &Hit{Troop: troop, Damage: damage, First: first, Traveled: traveled, Target: target, Splash: splash}
+	for _, a := range abilitiesOf(troop) {
+		if a.Hit != nil {
+			a.Hit(h)
+		}
+	}
+	return h
+}
+
+// Label là ghi chú hiệu ứng cho combat log, vd. " [charge, stun]".
+func (h *Hit) Label() string {
+	if len(h.Effects) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(h.Effects, ", ") + "]"
+}
+
+// castHeal cho troop hồi tối đa "amount" HP cho tower yếu nhất của người chơi,
+// không vượt quá "max_hp".
+func castHeal(s *State, player int, troop models.Troop) ([]Event, error) {
+	amount := int(Param(troop, "amount", float64(s.Rules.HealAmount)))
+	maxHP := int(Param(troop, "max_hp", float64(s.Rules.MaxHealHP)))
+	towers := s.Players[player].Towers
+	lowest := -1
+	for i, t := range towers {
+		if t.HP > 0 && (lowest < 0 || t.HP < towers[lowest].HP) {
+			lowest = i
+		}
+	}
+	if lowest < 0 {
+		return nil, ErrNothingToHeal
+	}
+
+	oldHP := towers[lowest].HP
+	heal := amount
+	if oldHP+heal > maxHP {
+		heal = maxHP - oldHP
+	}
+	if heal <= 0 {
+		return nil, ErrFullHP
+	}
+	towers[lowest].HP += heal
+	return []Event{Healed{Player: player, Troop: troop, Tower: lowest, Amount: heal, FromHP: oldHP, ToHP: towers[lowest].HP}}, nil
+}
+
+// spawnOnDeath trả về "count" troop tên "troop" từ rules.Troops.
+func spawnOnDeath(rules models.Ruleset, troop models.Troop) ([]models.Troop, error) {
+	name := paramString(troop, "troop", "Pawn")
+	for _, t := range rules.Troops {
+		if strings.EqualFold(t.Name, name) {
+			var spawned []models.Troop
+			for i := 0; i < int(Param(troop, "count", 1)); i++ {
+				spawned = append(spawned, t)
+			}
+			return spawned, nil
+		}
+	}
+	return nil, fmt.Errorf("%s spawns %q: %w", troop.Name, name, ErrUnknownTroop)
+}
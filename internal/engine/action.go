@@ -0,0 +1,240 @@
+package engine
+
+import "errors"
+
+// Lỗi của hành động không hợp lệ; khi đó Apply trả về nguyên State cũ.
+var (
+	ErrGameOver      = errors.New("the match is over")
+	ErrInvalidPlayer = errors.New("invalid player")
+	ErrNotYourTurn   = errors.New("not your turn")
+	ErrInvalidCard   = errors.New("invalid troop selection")
+	ErrNotEnoughMana = errors.New("not enough mana")
+	ErrInvalidTower  = errors.New("invalid tower selection")
+	ErrNoCrits       = errors.New("no crits left")
+	ErrCastCard      = errors.New("troop is cast, not deployed")
+	ErrNotCastable   = errors.New("troop cannot be cast")
+	ErrNothingToHeal = errors.New("no towers to heal")
+	ErrFullHP        = errors.New("tower already at full HP")
+)
+
+// Action là một hành động của người chơi trong trận theo lượt.
+type Action interface {
+	// Actor là người chơi (0 hoặc 1) thực hiện hành động.
+	Actor() int
+	apply(s *State) ([]Event, error)
+}
+
+// Deploy cho card Card trên tay đánh tower Tower của đối thủ.
+type Deploy struct {
+	Player int
+	Card   int
+	Tower  int
+}
+
+// Crit giống Deploy nhưng dùng một CRIT cho đòn đầu tiên.
+type Crit struct {
+	Player int
+	Card   int
+	Tower  int
+}
+
+// Heal dùng ngay card Card có ability cast (vd. hồi máu tower).
+type Heal struct {
+	Player int
+	Card   int
+}
+
+// Pass bỏ lượt.
+type Pass struct {
+	Player int
+}
+
+// Surrender đầu hàng; dùng được cả khi không phải lượt của mình.
+type Surrender struct {
+	Player int
+}
+
+func (a Deploy) Actor() int    { return a.Player }
+func (a Crit) Actor() int      { return a.Player }
+func (a Heal) Actor() int      { return a.Player }
+func (a Pass) Actor() int      { return a.Player }
+func (a Surrender) Actor() int { return a.Player }
+
+func (a Deploy) apply(s *State) ([]Event, error) {
+	return s.attack(a.Player, a.Card, a.Tower, false)
+}
+
+func (a Crit) apply(s *State) ([]Event, error) {
+	return s.attack(a.Player, a.Card, a.Tower, true)
+}
+
+func (a Heal) apply(s *State) ([]Event, error) {
+	return s.cast(a.Player, a.Card)
+}
+
+func (a Pass) apply(s *State) ([]Event, error) {
+	return []Event{Passed{Player: a.Player}}, nil
+}
+
+func (a Surrender) apply(s *State) ([]Event, error) {
+	return []Event{s.end(Opponent(a.Player), ReasonSurrender)}, nil
+}
+
+// Apply thực hiện action trên một bản sao của s và trả về trạng thái mới cùng
+// các sự kiện đã xảy ra. Hành động hoàn tất thì lượt chuyển cho đối thủ.
+func Apply(s State, a Action) (State, []Event, error) {
+	if s.Over {
+		return s, nil, ErrGameOver
+	}
+	player := a.Actor()
+	if player != 0 && player != 1 {
+		return s, nil, ErrInvalidPlayer
+	}
+	if _, ok := a.(Surrender); !ok && player != s.Turn {
+		return s, nil, ErrNotYourTurn
+	}
+
+	next := s.Clone()
+	events, err := a.apply(&next)
+	if err != nil {
+		return s, nil, err
+	}
+	if !next.Over {
+		next.Turn = Opponent(player)
+		events = append(events, TurnStarted{Player: next.Turn})
+	}
+	return next, events, nil
+}
+
+// LegalActions liệt kê mọi hành động hợp lệ của người chơi đang có lượt.
+func LegalActions(s State) []Action {
+	if s.Over {
+		return nil
+	}
+	player := s.Turn
+	me := s.Players[player]
+	var actions []Action
+	for card, troop := range me.Hand {
+		if troop.Mana > me.Mana {
+			continue
+		}
+		if a := castAbility(troop); a != nil {
+			next := s.Clone()
+			if _, err := a.Cast(&next, player, troop); err == nil {
+				actions = append(actions, Heal{Player: player, Card: card})
+			}
+			continue
+		}
+		for _, tower := range s.Targets(player) {
+			actions = append(actions, Deploy{Player: player, Card: card, Tower: tower})
+			if me.CritsLeft > 0 {
+				actions = append(actions, Crit{Player: player, Card: card, Tower: tower})
+			}
+		}
+	}
+	return append(actions, Pass{Player: player}, Surrender{Player: player})
+}
+
+// Play dùng card index của player ngoài lượt (chế độ real-time): trừ mana và
+// rút card kế tiếp. Troop ra trận do lớp gọi tự mô phỏng.
+func Play(s State, player, index int) (State, []Event, error) {
+	troop, err := s.card(player, index)
+	if err != nil {
+		return s, nil, err
+	}
+	if castAbility(troop) != nil {
+		return s, nil, ErrCastCard
+	}
+	next := s.Clone()
+	return next, next.play(player, index), nil
+}
+
+// Cast dùng ngay card index có ability cast của player ngoài lượt (chế độ
+// real-time). Card chỉ tốn mana khi dùng được.
+func Cast(s State, player, index int) (State, []Event, error) {
+	next := s.Clone()
+	events, err := next.cast(player, index)
+	if err != nil {
+		return s, nil, err
+	}
+	return next, events, nil
+}
+
+// EndByTime kết thúc trận khi hết giờ: ai phá nhiều tower hơn thì thắng.
+func EndByTime(s State) (State, []Event) {
+	if s.Over {
+		return s, nil
+	}
+	next := s.Clone()
+	winner := -1
+	switch p1, p2 := next.destroyed(1), next.destroyed(0); {
+	case p1 > p2:
+		winner = 0
+	case p2 > p1:
+		winner = 1
+	}
+	return next, []Event{next.end(winner, ReasonTime)}
+}
+
+// attack cho card index của player đánh tower target; các tower bị phá (kể cả
+// do splash hoặc damage theo thời gian) được báo sau combat.
+func (s *State) attack(player, index, target int, crit bool) ([]Event, error) {
+	troop, err := s.card(player, index)
+	if err != nil {
+		return nil, err
+	}
+	if castAbility(troop) != nil {
+		return nil, ErrCastCard
+	}
+	if crit && s.Players[player].CritsLeft <= 0 {
+		return nil, ErrNoCrits
+	}
+	if !s.attackable(player, target) {
+		return nil, ErrInvalidTower
+	}
+	if crit {
+		s.Players[player].CritsLeft--
+	}
+
+	defender := Opponent(player)
+	towers := s.Players[defender].Towers
+	alive := make([]bool, len(towers))
+	for i, t := range towers {
+		alive[i] = t.HP > 0
+	}
+	attacked, err := s.fight(player, troop, crit, target)
+	if err != nil {
+		return nil, err
+	}
+	attacked.TowerHP = towers[target].HP
+	events := []Event{attacked}
+	events = append(events, s.play(player, index)...)
+
+	var ended []Event
+	for i, t := range towers {
+		if alive[i] && t.HP <= 0 {
+			events = append(events, TowerDestroyed{Owner: defender, Tower: i})
+			if t.Type == KingTower && !s.Over {
+				ended = append(ended, s.end(player, ReasonKing))
+			}
+		}
+	}
+	return append(events, ended...), nil
+}
+
+// cast dùng ngay card index có ability cast rồi trừ mana và rút bài.
+func (s *State) cast(player, index int) ([]Event, error) {
+	troop, err := s.card(player, index)
+	if err != nil {
+		return nil, err
+	}
+	a := castAbility(troop)
+	if a == nil {
+		return nil, ErrNotCastable
+	}
+	events, err := a.Cast(s, player, troop)
+	if err != nil {
+		return nil, err
+	}
+	return append(events, s.play(player, index)...), nil
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/models"
+)
+
+var (
+	pawn   = models.Troop{Name: "Pawn", HP: 50, ATK: 150, DEF: 100, Mana: 3}
+	knight = models.Troop{Name: "Knight", HP: 200, ATK: 300, DEF: 150, Mana: 5}
+	queen  = models.Troop{Name: "Queen", Mana: 5, Special: "heal", Params: map[string]interface{}{"amount": 200.0, "max_hp": 1000.0}}
+)
+
+// newTestState tạo trận với King Tower ở index 0 và hai Guard Tower không CRIT;
+// mỗi người chơi cầm Pawn và Queen, Knight là card kế tiếp.
+func newTestState() State {
+	towers := []models.Tower{
+		{Type: KingTower, HP: 2000, ATK: 500, DEF: 300},
+		{Type: "Guard Tower", HP: 1000, ATK: 300, DEF: 100},
+		{Type: "Guard Tower", HP: 1000, ATK: 300, DEF: 100},
+	}
+	types := []string{KingTower, "Guard Tower", "Guard Tower"}
+	rules := models.DefaultRuleset()
+	rules.Troops = []models.Troop{pawn, knight, queen}
+
+	var players [2]PlayerState
+	for i := range players {
+		players[i] = PlayerState{
+			Name:      []string{"alice", "bob"}[i],
+			Towers:    append([]models.Tower(nil), towers...),
+			Hand:      []models.Troop{pawn, queen},
+			DrawPile:  []models.Troop{knight},
+			Mana:      rules.StartMana,
+			CritsLeft: rules.MaxCritsPerGame,
+		}
+	}
+	return NewState(rules, arena.New(types, types), players, 1)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(s *State)
+		action  Action
+		wantErr error
+		check   func(t *testing.T, s State, events []Event)
+	}{
+		{
+			name:   "pass",
+			action: Pass{Player: 0},
+			check: func(t *testing.T, s State, events []Event) {
+				want := []Event{Passed{Player: 0}, TurnStarted{Player: 1}}
+				if !reflect.DeepEqual(events, want) {
+					t.Fatalf("events = %#v, want %#v", events, want)
+				}
+			},
+		},
+		{
+			name:   "deploy plays the card and draws the next one",
+			action: Deploy{Player: 0, Card: 0, Tower: 1},
+			check: func(t *testing.T, s State, events []Event) {
+				me := s.Players[0]
+				if me.Mana != 10-pawn.Mana {
+					t.Errorf("mana = %d, want %d", me.Mana, 10-pawn.Mana)
+				}
+				if me.Hand[0].Name != knight.Name || me.DrawPile[0].Name != pawn.Name {
+					t.Errorf("hand %v, draw pile %v; want Knight drawn and Pawn at the back", me.Hand, me.DrawPile)
+				}
+				if s.Players[1].Towers[1].HP >= 1000 {
+					t.Errorf("guard tower HP = %d, want damage", s.Players[1].Towers[1].HP)
+				}
+				if s.Turn != 1 {
+					t.Errorf("turn = %d, want 1", s.Turn)
+				}
+			},
+		},
+		{
+			name:   "crit uses one crit",
+			action: Crit{Player: 0, Card: 0, Tower: 2},
+			check: func(t *testing.T, s State, events []Event) {
+				if s.Players[0].CritsLeft != 4 {
+					t.Errorf("crits left = %d, want 4", s.Players[0].CritsLeft)
+				}
+			},
+		},
+		{
+			name:   "heal the lowest tower",
+			setup:  func(s *State) { s.Players[0].Towers[2].HP = 500 },
+			action: Heal{Player: 0, Card: 1},
+			check: func(t *testing.T, s State, events []Event) {
+				if hp := s.Players[0].Towers[2].HP; hp != 700 {
+					t.Errorf("healed tower HP = %d, want 700", hp)
+				}
+			},
+		},
+		{
+			name:   "surrender on turn",
+			action: Surrender{Player: 0},
+			check: func(t *testing.T, s State, events []Event) {
+				if !s.Over || s.Winner != 1 || s.Reason != ReasonSurrender {
+					t.Errorf("over %v winner %d reason %q, want player 1 to win by surrender", s.Over, s.Winner, s.Reason)
+				}
+			},
+		},
+		{
+			name:   "surrender off turn",
+			action: Surrender{Player: 1},
+			check: func(t *testing.T, s State, events []Event) {
+				if !s.Over || s.Winner != 0 || s.Reason != ReasonSurrender {
+					t.Errorf("over %v winner %d reason %q, want player 0 to win by surrender", s.Over, s.Winner, s.Reason)
+				}
+				for _, ev := range events {
+					if _, ok := ev.(TurnStarted); ok {
+						t.Error("a turn started after the match ended")
+					}
+				}
+			},
+		},
+		{name: "off turn", action: Deploy{Player: 1, Card: 0, Tower: 1}, wantErr: ErrNotYourTurn},
+		{name: "pass off turn", action: Pass{Player: 1}, wantErr: ErrNotYourTurn},
+		{name: "invalid player", action: Pass{Player: 2}, wantErr: ErrInvalidPlayer},
+		{name: "game over", setup: func(s *State) { s.Over = true }, action: Surrender{Player: 0}, wantErr: ErrGameOver},
+		{name: "invalid card", action: Deploy{Player: 0, Card: 5, Tower: 1}, wantErr: ErrInvalidCard},
+		{name: "not enough mana", setup: func(s *State) { s.Players[0].Mana = 2 }, action: Deploy{Player: 0, Card: 0, Tower: 1}, wantErr: ErrNotEnoughMana},
+		{name: "king behind guards", action: Deploy{Player: 0, Card: 0, Tower: 0}, wantErr: ErrInvalidTower},
+		{name: "no crits left", setup: func(s *State) { s.Players[0].CritsLeft = 0 }, action: Crit{Player: 0, Card: 0, Tower: 1}, wantErr: ErrNoCrits},
+		{name: "deploy a cast card", action: Deploy{Player: 0, Card: 1, Tower: 1}, wantErr: ErrCastCard},
+		{name: "cast a troop", action: Heal{Player: 0, Card: 0}, wantErr: ErrNotCastable},
+		{name: "heal at full HP", action: Heal{Player: 0, Card: 1}, wantErr: ErrFullHP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestState()
+			if tt.setup != nil {
+				tt.setup(&s)
+			}
+			before := s.Clone()
+			next, events, err := Apply(s, tt.action)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply(%#v) error = %v, want %v", tt.action, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(s, before) {
+				t.Fatal("Apply modified the state passed in")
+			}
+			if err != nil {
+				if !reflect.DeepEqual(next, before) || events != nil {
+					t.Fatal("failed Apply did not return the old state")
+				}
+				return
+			}
+			tt.check(t, next, events)
+		})
+	}
+}
+
+func TestApplySpawnOnDeath(t *testing.T) {
+	egg := models.Troop{Name: "Egg", HP: 1, ATK: 1, Mana: 1, Special: "spawn", Params: map[string]interface{}{"troop": "Pawn", "count": 2.0}}
+
+	s := newTestState()
+	s.Players[0].Hand[0] = egg
+	next, events, err := Apply(s, Deploy{Player: 0, Card: 0, Tower: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacked := events[0].(Attacked)
+	pawns := 0
+	for _, e := range attacked.Log {
+		if e.Attacker == pawn.Name {
+			pawns++
+		}
+	}
+	if pawns == 0 {
+		t.Fatalf("no Pawn fought after the Egg died: %+v", attacked.Log)
+	}
+	if next.Players[1].Towers[1].HP >= 1000 {
+		t.Errorf("guard tower HP = %d, want damage from the spawned Pawns", next.Players[1].Towers[1].HP)
+	}
+
+	// Troop sinh ra phải có trong rules.Troops
+	s = newTestState()
+	s.Rules.Troops = nil
+	s.Players[0].Hand[0] = egg
+	if _, _, err := Apply(s, Deploy{Player: 0, Card: 0, Tower: 1}); !errors.Is(err, ErrUnknownTroop) {
+		t.Fatalf("Apply without a troop catalog = %v, want ErrUnknownTroop", err)
+	}
+}
+
+func TestLegalActions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *State)
+		want  []Action
+	}{
+		{
+			name: "start of match",
+			want: []Action{
+				Deploy{0, 0, 1}, Crit{0, 0, 1}, Deploy{0, 0, 2}, Crit{0, 0, 2},
+				Pass{0}, Surrender{0},
+			},
+		},
+		{
+			name:  "damaged tower can be healed",
+			setup: func(s *State) { s.Players[0].Towers[1].HP = 100 },
+			want: []Action{
+				Deploy{0, 0, 1}, Crit{0, 0, 1}, Deploy{0, 0, 2}, Crit{0, 0, 2}, Heal{0, 1},
+				Pass{0}, Surrender{0},
+			},
+		},
+		{
+			name:  "no crits left",
+			setup: func(s *State) { s.Players[0].CritsLeft = 0 },
+			want:  []Action{Deploy{0, 0, 1}, Deploy{0, 0, 2}, Pass{0}, Surrender{0}},
+		},
+		{
+			name:  "king reachable after a guard falls",
+			setup: func(s *State) { s.Players[1].Towers[1].HP = 0; s.Players[0].CritsLeft = 0 },
+			want:  []Action{Deploy{0, 0, 0}, Deploy{0, 0, 2}, Pass{0}, Surrender{0}},
+		},
+		{
+			name:  "no mana",
+			setup: func(s *State) { s.Players[0].Mana = 0 },
+			want:  []Action{Pass{0}, Surrender{0}},
+		},
+		{
+			name:  "player 2's turn",
+			setup: func(s *State) { s.Turn = 1; s.Players[1].Mana = 0 },
+			want:  []Action{Pass{1}, Surrender{1}},
+		},
+		{
+			name:  "match over",
+			setup: func(s *State) { s.Over = true },
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestState()
+			if tt.setup != nil {
+				tt.setup(&s)
+			}
+			got := LegalActions(s)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("LegalActions = %v, want %v", got, tt.want)
+			}
+			for _, a := range got {
+				if _, _, err := Apply(s, a); err != nil {
+					t.Errorf("legal action %#v rejected: %v", a, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"math"
+	"strings"
+
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/utils"
+)
+
+// covering trả về các tower của đối thủ bắn vào troop của player đang đứng
+// đánh tower target: những tower có tầm bắn phủ tới vị trí của troop trên lane.
+func (s State) covering(player, target int) []int {
+	side := arena.Side(player)
+	alive := TowerAlive(s.Players[Opponent(player)].Towers)
+	building, ok := s.Arena.Building(side.Opponent(), target)
+	if !ok {
+		return []int{target}
+	}
+	lane, _ := s.Arena.LaneTo(side, target, alive)
+	return s.Arena.Covering(side.Opponent(), lane, arena.FightPos(side, building), alive)
+}
+
+// TowerDamage tính damage tower t bắn vào troop; tower CRIT khi roll (trong
+// [0, 1)) nhỏ hơn tỉ lệ CRIT của nó.
+func TowerDamage(t models.Tower, troop models.Troop, roll float64, rules models.Ruleset) (int, bool) {
+	crit := roll < t.CRIT
+	return utils.CalculateDamage(t.ATK, troop.DEF, crit, rules.CritMultiplier), crit
+}
+
+// fight cho troop của player đánh tower target qua nhiều hiệp cho tới khi troop
+// chết hoặc tower bị phá; các tower phủ tới troop bắn trả mỗi hiệp. Troop xuất
+// hiện khi troop chết (ability spawn) đánh tiếp trong các hiệp còn lại.
+// CRIT của người chơi chỉ áp dụng cho đòn đầu tiên.
+func (s *State) fight(player int, troop models.Troop, useCrit bool, target int) (Attacked, error) {
+	out := Attacked{Player: player, Troop: troop, Tower: target, Crit: useCrit}
+	side := arena.Side(player)
+	towers := s.Players[Opponent(player)].Towers
+	tower := &towers[target]
+	covering := s.covering(player, target)
+	towerFx := make([]Effects, len(towers))
+	lane, _ := s.Arena.LaneTo(side, target, TowerAlive(towers))
+	splash := Splash(s.Arena, side.Opponent(), towers, target, lane, func(i, damage int) {
+		t := towers[i]
+		out.Log = append(out.Log, LogEntry{Attacker: "splash", Target: t.Type, Damage: damage, HPLeft: t.HP, Effect: "splash"})
+	})
+
+	// Quãng đường từ chỗ xuất hiện tới tower, dùng cho ability charge
+	traveled := 0.0
+	if b, ok := s.Arena.Building(side.Opponent(), target); ok {
+		traveled = math.Abs(arena.FightPos(side, b) - arena.SpawnPos(side))
+	}
+
+	fighters := []models.Troop{troop}
+	round := 1
+	for len(fighters) > 0 && round <= s.Rules.MaxCombatRounds {
+		cur := fighters[0]
+		fighters = fighters[1:]
+		fx := SpawnEffects(cur)
+		out.TroopHP = cur.HP
+		first := true
+
+		for ; round <= s.Rules.MaxCombatRounds; round++ {
+			for i := range towerFx {
+				if damage := towerFx[i].Tick(); damage > 0 && towers[i].HP > 0 {
+					t := &towers[i]
+					t.HP -= damage
+					out.Log = append(out.Log, LogEntry{
+						Round: round, Attacker: towerFx[i].DotSource(), Target: t.Type, Damage: damage, HPLeft: t.HP, Effect: "dot",
+					})
+				}
+			}
+			if tower.HP <= 0 {
+				return out, nil
+			}
+
+			crit := useCrit && round == 1
+			h := NewHit(cur, utils.CalculateDamage(cur.ATK, tower.DEF, crit, s.Rules.CritMultiplier), first, traveled, &towerFx[target], splash)
+			first, traveled = false, 0
+			tower.HP -= h.Damage
+			out.Damage += h.Damage
+			out.Log = append(out.Log, LogEntry{
+				Round: round, Attacker: cur.Name, Target: tower.Type, Damage: h.Damage, Crit: crit, HPLeft: tower.HP,
+				Effect: strings.Join(h.Effects, ","),
+			})
+			if tower.HP <= 0 {
+				return out, nil
+			}
+
+			for _, i := range covering {
+				t := towers[i]
+				if t.HP <= 0 || towerFx[i].Stun > 0 {
+					continue
+				}
+				damage, crit := TowerDamage(t, cur, s.roll(), s.Rules)
+				taken := fx.Absorb(damage)
+				out.TroopHP -= taken
+				entry := LogEntry{Round: round, Attacker: t.Type, Target: cur.Name, Damage: taken, Crit: crit, HPLeft: out.TroopHP}
+				if taken < damage {
+					entry.Effect = "shield"
+				}
+				out.Log = append(out.Log, entry)
+				if out.TroopDied() {
+					spawned, err := DeathSpawns(s.Rules, cur)
+					if err != nil {
+						return out, err
+					}
+					fighters = append(fighters, spawned...)
+					break
+				}
+			}
+			if out.TroopDied() {
+				round++
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// Splash trả về hàm gây splash damage cho các tower khác (của side) trên lane
+// và trong bán kính quanh tower target; onHit được gọi sau mỗi tower trúng
+// splash.
+func Splash(a *arena.Arena, side arena.Side, towers []models.Tower, target int, lane arena.Lane, onHit func(index, damage int)) func(int, float64) {
+	alive := TowerAlive(towers)
+	center, ok := a.Building(side, target)
+	if !ok {
+		return nil
+	}
+	return func(damage int, radius float64) {
+		for _, b := range a.Buildings(side) {
+			if b.Index == target || !alive(b.Index) || !b.InLane(lane) || math.Abs(b.Pos-center.Pos) > radius {
+				continue
+			}
+			towers[b.Index].HP -= damage
+			onHit(b.Index, damage)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import "net-centric-clash-royale/internal/models"
+
+// Event là một thay đổi của trận do hành động tạo ra, theo đúng thứ tự xảy ra.
+// Lớp mạng chuyển mỗi Event thành message cho người chơi và người xem.
+type Event interface {
+	event()
+}
+
+// CardPlayed: player đã dùng Card.
+type CardPlayed struct {
+	Player int
+	Card   models.Troop
+}
+
+// CardDrawn: Card từ hàng chờ vào tay player.
+type CardDrawn struct {
+	Player int
+	Card   models.Troop
+}
+
+// LogEntry là một đòn đánh trong combat log.
+type LogEntry struct {
+	Round    int
+	Attacker string
+	Target   string
+	Damage   int
+	Crit     bool
+	HPLeft   int
+	Effect   string
+}
+
+// Attacked: troop của Player đánh tower Tower của đối thủ. Damage là tổng damage
+// troop gây ra cho tower mục tiêu; Log có cả các đòn tower bắn trả.
+type Attacked struct {
+	Player  int
+	Troop   models.Troop
+	Tower   int
+	Crit    bool
+	Damage  int
+	TowerHP int
+	TroopHP int
+	Log     []LogEntry
+}
+
+// TroopDied cho biết troop đã bị tower bắn chết.
+func (a Attacked) TroopDied() bool {
+	return a.TroopHP <= 0
+}
+
+// Healed: Troop của Player hồi Amount HP cho tower Tower của mình.
+type Healed struct {
+	Player int
+	Troop  models.Troop
+	Tower  int
+	Amount int
+	FromHP int
+	ToHP   int
+}
+
+// TowerDestroyed: tower Tower của Owner bị phá.
+type TowerDestroyed struct {
+	Owner int
+	Tower int
+}
+
+// Passed: Player bỏ lượt.
+type Passed struct {
+	Player int
+}
+
+// TurnStarted: tới lượt của Player.
+type TurnStarted struct {
+	Player int
+}
+
+// GameEnded: trận kết thúc. Winner là -1 nếu hoà; Destroyed là số tower mỗi
+// người chơi đã phá.
+type GameEnded struct {
+	Winner    int
+	Reason    string
+	Destroyed [2]int
+}
+
+func (CardPlayed) event()     {}
+func (CardDrawn) event()      {}
+func (Attacked) event()       {}
+func (Healed) event()         {}
+func (TowerDestroyed) event() {}
+func (Passed) event()         {}
+func (TurnStarted) event()    {}
+func (GameEnded) event()      {}
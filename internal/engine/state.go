@@ -0,0 +1,148 @@
+// Package engine chứa luật chơi của trận đấu, tách khỏi phần mạng: trạng thái
+// trận (State), hành động của người chơi (Action) và các sự kiện (Event) mà
+// một hành động tạo ra. Apply không sửa State được truyền vào, nên engine dùng
+// được cho bot, mô phỏng và kiểm thử mà không cần kết nối nào.
+package engine
+
+import (
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/models"
+)
+
+// KingTower là loại tower mà khi bị phá thì trận kết thúc.
+const KingTower = "King Tower"
+
+// Lý do kết thúc trận trong GameEnded.
+const (
+	ReasonKing      = "king_destroyed"
+	ReasonTime      = "time_up"
+	ReasonSurrender = "surrender"
+)
+
+// PlayerState là phần trạng thái trận của một người chơi.
+type PlayerState struct {
+	Name      string
+	Towers    []models.Tower
+	Hand      []models.Troop
+	DrawPile  []models.Troop // card đầu là card kế tiếp
+	Mana      int
+	CritsLeft int
+}
+
+// State là trạng thái của một trận. Người chơi được đánh số 0 (Player1, ở
+// arena.Home) và 1 (Player2, ở arena.Away).
+type State struct {
+	Rules   models.Ruleset
+	Arena   *arena.Arena
+	Players [2]PlayerState
+	Turn    int    // người chơi đang có lượt
+	RNG     uint64 // trạng thái bộ sinh số ngẫu nhiên cho CRIT của tower
+	Over    bool
+	Winner  int // -1 nếu hoà hoặc chưa kết thúc
+	Reason  string
+}
+
+// NewState tạo trạng thái đầu trận; Player1 đi trước.
+func NewState(rules models.Ruleset, a *arena.Arena, players [2]PlayerState, seed uint64) State {
+	return State{Rules: rules, Arena: a, Players: players, RNG: seed, Winner: -1}
+}
+
+// Opponent trả về đối thủ của người chơi player.
+func Opponent(player int) int {
+	return 1 - player
+}
+
+// Targets trả về vị trí các tower của đối thủ mà troop của player đi tới
+// được theo một lane. King Tower chỉ tới được khi Guard Tower trên lane đó đã
+// bị phá.
+func (s State) Targets(player int) []int {
+	return s.Arena.Reachable(arena.Side(player), TowerAlive(s.Players[Opponent(player)].Towers))
+}
+
+func (s State) attackable(player, index int) bool {
+	for _, i := range s.Targets(player) {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// TowerAlive trả về hàm cho biết tower index còn đứng hay không.
+func TowerAlive(towers []models.Tower) func(int) bool {
+	return func(i int) bool {
+		return i >= 0 && i < len(towers) && towers[i].HP > 0
+	}
+}
+
+// Clone sao chép các slice của người chơi để thay đổi không ảnh hưởng tới s.
+func (s State) Clone() State {
+	for i, p := range s.Players {
+		p.Towers = append([]models.Tower(nil), p.Towers...)
+		p.Hand = append([]models.Troop(nil), p.Hand...)
+		p.DrawPile = append([]models.Troop(nil), p.DrawPile...)
+		s.Players[i] = p
+	}
+	return s
+}
+
+// roll trả về số ngẫu nhiên trong [0, 1) (splitmix64) và tiến trạng thái RNG.
+func (s *State) roll() float64 {
+	s.RNG += 0x9E3779B97F4A7C15
+	z := s.RNG
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return float64(z>>11) / (1 << 53)
+}
+
+// card trả về card index trên tay player nếu người chơi đủ mana để dùng nó.
+func (s State) card(player, index int) (models.Troop, error) {
+	hand := s.Players[player].Hand
+	if index < 0 || index >= len(hand) {
+		return models.Troop{}, ErrInvalidCard
+	}
+	if s.Players[player].Mana < hand[index].Mana {
+		return models.Troop{}, ErrNotEnoughMana
+	}
+	return hand[index], nil
+}
+
+// CheckCard kiểm tra player có dùng được card index trên tay hay không.
+func CheckCard(s State, player, index int) error {
+	_, err := s.card(player, index)
+	return err
+}
+
+// play trừ mana của card index; card xuống cuối hàng chờ và card kế tiếp
+// trong hàng chờ vào đúng chỗ trống trên tay.
+func (s *State) play(player, index int) []Event {
+	p := &s.Players[player]
+	played := p.Hand[index]
+	p.Mana -= played.Mana
+	events := []Event{CardPlayed{Player: player, Card: played}}
+	if len(p.DrawPile) == 0 {
+		p.Hand = append(p.Hand[:index], p.Hand[index+1:]...)
+		return events
+	}
+	next := p.DrawPile[0]
+	p.Hand[index] = next
+	p.DrawPile = append(p.DrawPile[1:], played)
+	return append(events, CardDrawn{Player: player, Card: next})
+}
+
+// destroyed đếm số tower của player đã bị phá.
+func (s State) destroyed(player int) int {
+	count := 0
+	for _, t := range s.Players[player].Towers {
+		if t.HP <= 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *State) end(winner int, reason string) GameEnded {
+	s.Over, s.Winner, s.Reason = true, winner, reason
+	return GameEnded{Winner: winner, Reason: reason, Destroyed: [2]int{s.destroyed(1), s.destroyed(0)}}
+}
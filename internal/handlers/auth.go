@@ -1,25 +1,23 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
-	"net"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 
 	"net-centric-clash-royale/internal/models"
 	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
 	"net-centric-clash-royale/internal/utils"
 )
 
-var userDataFile = filepath.Join("data", "players.json")
+// registerMutex giữ cho việc kiểm tra username và tạo player là một bước.
+var registerMutex sync.Mutex
 
-func Authenticate(conn net.Conn, players *map[string]*models.Player, mutex *sync.Mutex) *models.Player {
+func Authenticate(conn *network.Conn, store storage.PlayerStore) *models.Player {
 	for {
-		network.SendPDU(conn, "menu", "📋 Do you want to (1) Register or (2) Login? Enter 1 or 2:")
-		pdu, err := network.ReadPDU(conn)
+		conn.SendPDU("menu", "📋 Do you want to (1) Register or (2) Login? Enter 1 or 2:")
+		pdu, err := conn.ReadPDU()
 		if err != nil {
 			fmt.Println("❌ Failed to read PDU:", err)
 			return nil
@@ -28,130 +26,188 @@ func Authenticate(conn net.Conn, players *map[string]*models.Player, mutex *sync
 
 		switch choice {
 		case "1":
-			player := register(conn, players, mutex)
+			player := register(conn, store)
 			if player != nil {
 				return player
 			}
 		case "2":
-			player := login(conn, players, mutex)
+			player := login(conn, store)
 			if player != nil {
 				return player
 			}
 		default:
-			network.SendPDU(conn, "error", "❗ Invalid option. Please enter 1 or 2.")
+			conn.SendPDU("error", "❗ Invalid option. Please enter 1 or 2.")
 		}
 	}
 }
 
-func register(conn net.Conn, players *map[string]*models.Player, mutex *sync.Mutex) *models.Player {
-	network.SendPDU(conn, "input", "🆕 Enter a new username:")
-	usernamePDU, err := network.ReadPDU(conn)
+// ResumeSession logs a player in with a session token from the handshake.
+// It returns nil if the token is unknown or expired.
+func ResumeSession(conn *network.Conn, token string, store storage.PlayerStore, tokens *TokenStore) *models.Player {
+	username, ok := tokens.Lookup(token)
+	if !ok {
+		conn.SendPDU("error", "⚠️ Session expired. Please log in again.")
+		return nil
+	}
+
+	player, exists := store.Get(username)
+	if !exists {
+		tokens.Revoke(token)
+		conn.SendPDU("error", "⚠️ Session expired. Please log in again.")
+		return nil
+	}
+
+	if !prepareSession(conn, player) {
+		return nil
+	}
+	conn.SendPDU("success", "✅ Session resumed!")
+	return player
+}
+
+// prepareSession nạp những phần player cần để vào trận mà store chưa có;
+// dùng chung cho đăng nhập bằng mật khẩu và bằng session token.
+func prepareSession(conn *network.Conn, player *models.Player) bool {
+	// Kiểm tra nếu chưa có towers thì nạp từ file
+	if len(player.Towers) == 0 {
+		towers, err := utils.LoadPlayerTowers()
+		if err != nil {
+			conn.SendPDU("error", "❌ Failed to load towers.")
+			return false
+		}
+		player.Towers = towers
+	}
+
+	// Nếu chưa có troops thì khởi tạo rỗng
+	if player.Troops == nil {
+		player.Troops = []models.Troop{}
+	}
+	return true
+}
+
+// IssueSessionToken tạo token mới và gửi cho client (client cũ không nhận được vì không có text).
+func IssueSessionToken(conn *network.Conn, tokens *TokenStore, username string) {
+	token, expires, err := tokens.Issue(username)
+	if err != nil {
+		fmt.Printf("❌ Failed to issue session token for %s: %v\n", username, err)
+		return
+	}
+	conn.Send(network.MsgSessionToken, "", network.SessionToken{Token: token, ExpiresAt: expires})
+}
+
+func register(conn *network.Conn, store storage.PlayerStore) *models.Player {
+	conn.SendPDU("input", "🆕 Enter a new username:")
+	usernamePDU, err := conn.ReadPDU()
 	if err != nil {
 		return nil
 	}
 	username := strings.TrimSpace(usernamePDU.Payload)
-	network.SendPDU(conn, "input", "🔒 Enter a password:")
-	passwordPDU, err := network.ReadPDU(conn)
+	conn.SendPDU("input", "🔒 Enter a password:")
+	passwordPDU, err := conn.ReadPDU()
 	if err != nil {
 		return nil
 	}
 	password := strings.TrimSpace(passwordPDU.Payload)
 
-	mutex.Lock()
-	defer mutex.Unlock()
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		conn.SendPDU("error", "❌ Failed to initialize player.")
+		return nil
+	}
+
+	registerMutex.Lock()
+	defer registerMutex.Unlock()
 
-	if _, exists := (*players)[username]; exists {
-		network.SendPDU(conn, "error", "❌ Username already exists.")
+	if _, exists := store.Get(username); exists {
+		conn.SendPDU("error", "❌ Username already exists.")
 		return nil
 	}
 
 	player := &models.Player{
-		Username: username,
-		Password: password,
+		Username:     username,
+		PasswordHash: hash,
 	}
 
 	if err := InitNewPlayer(player); err != nil {
-		network.SendPDU(conn, "error", "❌ Failed to initialize player.")
+		conn.SendPDU("error", "❌ Failed to initialize player.")
 		return nil
 	}
 
-	(*players)[username] = player
-	savePlayers(*players)
+	if err := store.Put(player); err != nil {
+		fmt.Printf("❌ Failed to save player data: %v\n", err)
+		conn.SendPDU("error", "❌ Failed to save player.")
+		return nil
+	}
 
-	network.SendPDU(conn, "success", "✅ Registration successful!")
+	conn.SendPDU("success", "✅ Registration successful!")
 	return player
 }
 
-func login(conn net.Conn, players *map[string]*models.Player, mutex *sync.Mutex) *models.Player {
-	network.SendPDU(conn, "input", "👤 Enter username:")
-	usernamePDU, err := network.ReadPDU(conn)
+func login(conn *network.Conn, store storage.PlayerStore) *models.Player {
+	conn.SendPDU("input", "👤 Enter username:")
+	usernamePDU, err := conn.ReadPDU()
 	if err != nil {
 		return nil
 	}
 	username := strings.TrimSpace(usernamePDU.Payload)
 
-	network.SendPDU(conn, "input", "🔑 Enter password:")
-	passwordPDU, err := network.ReadPDU(conn)
+	conn.SendPDU("input", "🔑 Enter password:")
+	passwordPDU, err := conn.ReadPDU()
 	if err != nil {
 		return nil
 	}
 	password := strings.TrimSpace(passwordPDU.Payload)
 
-	mutex.Lock()
-	defer mutex.Unlock()
+	player, exists := store.Get(username)
+	var storedHash, legacyPassword string
+	if exists {
+		storedHash, legacyPassword = player.PasswordHash, player.Password
+	}
 
-	if player, exists := (*players)[username]; exists && player.Password == password {
-		// Kiểm tra nếu chưa có towers thì nạp từ file
-		if len(player.Towers) == 0 {
-			towers, err := utils.LoadPlayerTowers()
-			if err != nil {
-				network.SendPDU(conn, "error", "❌ Failed to load towers.")
+	// So sánh ngoài mutex vì bcrypt chậm có chủ đích
+	ok := false
+	upgrade := false
+	if storedHash != "" {
+		ok = utils.CheckPassword(storedHash, password)
+	} else if legacyPassword != "" {
+		ok = utils.CheckLegacyPassword(legacyPassword, password)
+		upgrade = ok
+	} else {
+		utils.CheckPassword("", password)
+	}
+
+	var newHash string
+	if upgrade {
+		newHash, err = utils.HashPassword(password)
+		if err != nil {
+			fmt.Printf("❌ Failed to hash password for %s: %v\n", username, err)
+			upgrade = false
+		}
+	}
+
+	if ok {
+		// Nâng cấp mật khẩu plaintext cũ lên bcrypt ở lần đăng nhập đầu tiên
+		if upgrade {
+			err := store.Update(username, func(p *models.Player) error {
+				p.PasswordHash = newHash
+				p.Password = ""
 				return nil
+			})
+			if err != nil {
+				fmt.Printf("❌ Failed to save upgraded password for %s: %v\n", username, err)
+			} else {
+				// player là bản sao: sửa theo để lần lưu tiến trình sau không ghi lại mật khẩu cũ
+				player.PasswordHash, player.Password = newHash, ""
+				fmt.Printf("🔐 Upgraded password storage for %s\n", username)
 			}
-			player.Towers = towers
 		}
 
-		// Nếu chưa có troops thì khởi tạo rỗng
-		if player.Troops == nil {
-			player.Troops = []models.Troop{}
+		if !prepareSession(conn, player) {
+			return nil
 		}
-
-		network.SendPDU(conn, "success", "✅ Login successful!")
+		conn.SendPDU("success", "✅ Login successful!")
 		return player
 	}
 
-	network.SendPDU(conn, "error", "❌ Invalid username or password.")
+	conn.SendPDU("error", "❌ Invalid username or password.")
 	return nil
 }
-
-func savePlayers(players map[string]*models.Player) {
-	file, err := os.Create(userDataFile)
-	if err != nil {
-		fmt.Printf("❌ Failed to save player data: %v\n", err)
-		return
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(players); err != nil {
-		fmt.Printf("❌ Failed to encode player data: %v\n", err)
-	}
-}
-
-func LoadPlayers() (map[string]*models.Player, error) {
-	file, err := os.Open(userDataFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return make(map[string]*models.Player), nil
-		}
-		return nil, err
-	}
-	defer file.Close()
-
-	var players map[string]*models.Player
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&players); err != nil {
-		return nil, err
-	}
-	return players, nil
-}
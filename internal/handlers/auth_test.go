@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+	"net-centric-clash-royale/internal/utils"
+)
+
+// loginAs chạy login trên đầu server của một net.Pipe, trả lời các câu hỏi
+// username / password từ đầu client.
+func loginAs(t *testing.T, store storage.PlayerStore, username, password string) *models.Player {
+	t.Helper()
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	server, client := network.NewConn(a), network.NewConn(b)
+
+	go func() {
+		answers := []string{username, password}
+		for {
+			pdu, err := client.ReadPDU()
+			if err != nil {
+				return
+			}
+			if pdu.Type == "input" && len(answers) > 0 {
+				client.SendPDU("input", answers[0])
+				answers = answers[1:]
+			}
+		}
+	}()
+	return login(server, store)
+}
+
+func TestLoginUpgradesLegacyPasswordOnce(t *testing.T) {
+	store, err := storage.NewSQLStore(filepath.Join(t.TempDir(), "clash.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	legacy := &models.Player{
+		Username: "alice",
+		Password: "secret",
+		Level:    1,
+		Towers:   []models.Tower{{Type: "King Tower", HP: 2000, ATK: 500, DEF: 300}},
+	}
+	if err := store.Put(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		player := loginAs(t, store, "alice", "secret")
+		if player == nil {
+			t.Fatalf("login %d failed", i)
+		}
+		if player.Password != "" || player.PasswordHash == "" {
+			t.Fatalf("login %d returned password %q, hash %q; want upgraded hash only", i, player.Password, player.PasswordHash)
+		}
+
+		// Trận đấu lưu tiến trình bằng chính player đã đăng nhập
+		player.EXP += 10
+		if err := store.Put(player); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, ok := store.Get("alice")
+		if !ok {
+			t.Fatal("alice missing from store")
+		}
+		if stored.Password != "" {
+			t.Fatalf("after login %d the plaintext password was written back", i)
+		}
+		if !utils.CheckPassword(stored.PasswordHash, "secret") {
+			t.Fatalf("after login %d the stored hash does not match", i)
+		}
+	}
+
+	if player := loginAs(t, store, "alice", "wrong"); player != nil {
+		t.Fatal("login with wrong password succeeded")
+	}
+}
+
+func TestResumeSessionLoadsTowers(t *testing.T) {
+	t.Chdir("../..") // data/tower.json
+	store, err := storage.NewSQLStore(filepath.Join(t.TempDir(), "clash.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Put(&models.Player{Username: "bob", PasswordHash: "x", Level: 1}); err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokenStore(SessionTokenTTL)
+	token, _, err := tokens.Issue("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	go func() {
+		client := network.NewConn(b)
+		for {
+			if _, err := client.ReadPDU(); err != nil {
+				return
+			}
+		}
+	}()
+	player := ResumeSession(network.NewConn(a), token, store, tokens)
+	if player == nil {
+		t.Fatal("ResumeSession failed")
+	}
+	if len(player.Towers) == 0 || player.Troops == nil {
+		t.Fatalf("resumed player has towers %v, troops %v; want towers loaded like login", player.Towers, player.Troops)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/bot"
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+)
+
+// BotMenu hỏi độ khó của bot sau khi người chơi chọn đấu với bot.
+const BotMenu = "🤖 Choose bot difficulty:\n1. Easy\n2. Normal\n3. Hard\nEnter 1, 2 or 3:"
+
+// BotThinkTime là thời gian bot chờ trước mỗi nước đi để người chơi kịp theo
+// dõi. Có thể thay đổi khi khởi động server.
+var BotThinkTime = 1500 * time.Millisecond
+
+// chooseAction chọn nước đi cho bot; test thay thế để kiểm tra lựa chọn.
+var chooseAction = bot.Choose
+
+// ErrInvalidBotLevel là lỗi khi người chơi chọn độ khó không có.
+var ErrInvalidBotLevel = errors.New("invalid bot difficulty")
+
+// botPlayer là đối thủ do server điều khiển. Nó chơi qua một network.Conn
+// trong bộ nhớ như người chơi mạng: nhận mọi PDU của trận và gửi lệnh bằng
+// AttackRequest hoặc lựa chọn trong menu. Thay cho menu văn bản, vòng lặp
+// trận đấu gửi cho bot trạng thái engine mỗi khi tới lượt của nó.
+type botPlayer struct {
+	player *models.Player
+	level  bot.Level
+	conn   *network.Conn // đầu client của kết nối
+	turns  chan engine.State
+	done   chan struct{} // đóng khi kết nối của bot bị đóng
+	rng    *rand.Rand
+}
+
+// ParseBotRequest nhận lựa chọn "4" trong menu chế độ (rồi hỏi độ khó) hoặc
+// PDU play_bot. ok là false nếu PDU không phải yêu cầu đấu với bot; err là
+// ErrInvalidBotLevel hoặc lỗi đọc khi client ngắt kết nối.
+func ParseBotRequest(conn *network.Conn, pdu network.PDU) (level bot.Level, ok bool, err error) {
+	if pdu.Type == network.MsgPlayBot {
+		var req network.PlayBot
+		if pdu.HasData() {
+			if err := pdu.DecodeData(&req); err != nil {
+				return bot.Normal, true, ErrInvalidBotLevel
+			}
+		}
+		if req.Difficulty == "" {
+			return bot.Normal, true, nil
+		}
+		if level, ok := bot.ParseLevel(req.Difficulty); ok {
+			return level, true, nil
+		}
+		return bot.Normal, true, ErrInvalidBotLevel
+	}
+	if strings.TrimSpace(pdu.Payload) != "4" {
+		return bot.Normal, false, nil
+	}
+
+	conn.SendPDU("menu", BotMenu)
+	answer, err := conn.ReadPDU()
+	if err != nil {
+		return bot.Normal, true, err
+	}
+	if level, ok := bot.ParseLevel(answer.Payload); ok {
+		return level, true, nil
+	}
+	return bot.Normal, true, ErrInvalidBotLevel
+}
+
+// StartBotGame bắt đầu trận luyện tập theo lượt, không tính giờ, giữa player
+// và bot có độ khó level. Trận luyện tập không đổi rating, không có phần
+// thưởng và không lưu tiến trình của người chơi.
+func StartBotGame(player *models.Player, conn *network.Conn, level bot.Level, store storage.PlayerStore, rules models.Ruleset) chan bool {
+	serverEnd, clientEnd := net.Pipe()
+	botConn := network.NewConn(serverEnd)
+	botConn.SetLegacy(false)
+	b := &botPlayer{
+		player: newBotModel(player, level),
+		level:  level,
+		conn:   network.NewConn(clientEnd),
+		turns:  make(chan engine.State, 1),
+		done:   make(chan struct{}),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	b.conn.SetLegacy(false)
+	go b.read()
+	go b.play()
+
+	conn.SendPDU("info", fmt.Sprintf("🤖 Starting a practice match against the %s bot...", level))
+	return startGameSession(player, b.player, conn, botConn, models.ModeUntimed, store, rules, time.Now().UnixNano(), b)
+}
+
+// newBotModel tạo người chơi cho bot. Bot Hard dùng cấp card và tower của
+// người chơi để không bị lép vế về chỉ số.
+func newBotModel(opponent *models.Player, level bot.Level) *models.Player {
+	p := &models.Player{Username: fmt.Sprintf("🤖 Bot (%s)", level), Level: opponent.Level}
+	if level == bot.Hard {
+		p.CardLevels = maps.Clone(opponent.CardLevels)
+		p.TowerLevels = maps.Clone(opponent.TowerLevels)
+	}
+	return p
+}
+
+// practice cho biết trận là trận luyện tập với bot.
+func (gs *GameSession) practice() bool {
+	return gs.bot != nil
+}
+
+func (gs *GameSession) isBot(p *models.Player) bool {
+	return gs.bot != nil && gs.bot.player == p
+}
+
+// reward trao phần thưởng cho người chơi, trừ trong trận luyện tập.
+func (gs *GameSession) reward(p *models.Player, r models.Reward) {
+	if !gs.practice() {
+		reward(p, r)
+	}
+}
+
+// yourTurn gửi bản sao trạng thái trận cho bot khi tới lượt nó để goroutine
+// của bot không đọc chung slice với trận; trạng thái cũ chưa dùng bị thay
+// thế. Chỉ vòng lặp trận đấu gọi hàm này.
+func (b *botPlayer) yourTurn(s engine.State) {
+	select {
+	case <-b.turns:
+	default:
+	}
+	b.turns <- s.Clone()
+}
+
+// read đọc mọi PDU server gửi cho bot để kết nối không bị nghẽn; bot luôn
+// đồng ý chơi lại và dừng khi kết nối bị đóng.
+func (b *botPlayer) read() {
+	defer close(b.done)
+	for {
+		pdu, err := b.conn.ReadPDU()
+		if err != nil {
+			return
+		}
+		if pdu.Type == "menu" && strings.Contains(pdu.Payload, "play again") {
+			b.conn.SendPDU("input", "1")
+		}
+	}
+}
+
+// play chọn và gửi nước đi mỗi khi tới lượt bot.
+func (b *botPlayer) play() {
+	for {
+		select {
+		case s := <-b.turns:
+			select {
+			case <-time.After(BotThinkTime):
+			case <-b.done:
+				return
+			}
+			if err := b.send(s, chooseAction(s, b.level, b.rng)); err != nil {
+				return
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// send gửi action như một client: tấn công và hồi máu bằng AttackRequest, bỏ
+// lượt và đầu hàng bằng lựa chọn trong menu.
+func (b *botPlayer) send(s engine.State, action engine.Action) error {
+	hand := s.Players[action.Actor()].Hand
+	switch a := action.(type) {
+	case engine.Deploy:
+		return b.conn.Send(network.MsgAttackRequest, "", network.AttackRequest{Troop: hand[a.Card].Name, Tower: a.Tower})
+	case engine.Crit:
+		return b.conn.Send(network.MsgAttackRequest, "", network.AttackRequest{Troop: hand[a.Card].Name, Tower: a.Tower, UseCrit: true})
+	case engine.Heal:
+		return b.conn.Send(network.MsgAttackRequest, "", network.AttackRequest{Troop: hand[a.Card].Name})
+	case engine.Surrender:
+		return b.conn.SendPDU("input", "4")
+	}
+	return b.conn.SendPDU("input", "3")
+}
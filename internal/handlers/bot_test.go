@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"math/rand"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"net-centric-clash-royale/internal/bot"
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+	"net-centric-clash-royale/internal/utils"
+)
+
+// TestBotPlaysLegalActions chơi trọn một trận luyện tập với mỗi độ khó: người
+// chơi luôn bỏ lượt, và mọi nước đi của bot phải nằm trong
+// engine.LegalActions. Bot Hard không tấn công King Tower khi sát thương không
+// bõ mana bỏ ra, nên người chơi đầu hàng nếu trận kéo dài quá maxTurns lượt.
+func TestBotPlaysLegalActions(t *testing.T) {
+	t.Chdir("../..")
+	rules := models.DefaultRuleset()
+	var err error
+	if rules.Troops, err = utils.LoadTroopsFromFile(filepath.Join("data", "troop.json")); err != nil {
+		t.Fatal(err)
+	}
+	if rules.Levels, err = utils.LoadLevelCurves(filepath.Join("data", "levels.json")); err != nil {
+		t.Fatal(err)
+	}
+	// Đủ mana cho cả trận để không phải chờ hồi mana theo thời gian thực
+	rules.StartMana, rules.MaxMana = 1000, 1000
+	const maxTurns = 100
+
+	ReplayDir = t.TempDir()
+	thinkTime := BotThinkTime
+	BotThinkTime = 0
+	t.Cleanup(func() { BotThinkTime, chooseAction = thinkTime, bot.Choose })
+
+	var mu sync.Mutex
+	var illegal []engine.Action
+	moves := 0
+	chooseAction = func(s engine.State, level bot.Level, rng *rand.Rand) engine.Action {
+		a := bot.Choose(s, level, rng)
+		mu.Lock()
+		defer mu.Unlock()
+		moves++
+		if !slices.Contains(engine.LegalActions(s), a) {
+			illegal = append(illegal, a)
+		}
+		return a
+	}
+
+	for _, level := range []bot.Level{bot.Easy, bot.Normal, bot.Hard} {
+		t.Run(level.String(), func(t *testing.T) {
+			store, err := storage.NewSQLStore(filepath.Join(t.TempDir(), "clash.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			a, b := net.Pipe()
+			server, client := network.NewConn(a), network.NewConn(b)
+			server.SetLegacy(false)
+			client.SetLegacy(false)
+			player := &models.Player{Username: "alice", Level: 1}
+
+			over := make(chan network.GameOver, 1)
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				defer client.Close()
+				turns := 0
+				for {
+					pdu, err := client.ReadPDU()
+					if err != nil {
+						return
+					}
+					switch {
+					case pdu.Type == network.MsgGameOver:
+						var result network.GameOver
+						pdu.DecodeData(&result)
+						over <- result
+					case pdu.Type == "menu" && strings.Contains(pdu.Payload, "play again"):
+						client.SendPDU("input", "2")
+					case pdu.Type == "menu" && strings.Contains(pdu.Payload, "Your turn"):
+						if turns++; turns > maxTurns {
+							client.SendPDU("input", "4")
+						} else {
+							client.SendPDU("input", "3")
+						}
+					}
+				}
+			}()
+
+			gameOver := StartBotGame(player, server, level, store, rules)
+			select {
+			case result := <-over:
+				if !strings.HasPrefix(result.Winner, "🤖") {
+					t.Errorf("winner = %q, want the bot to beat a player who always passes", result.Winner)
+				}
+			case <-time.After(30 * time.Second):
+				t.Fatal("practice match did not end")
+			}
+			<-gameOver
+			// Chờ phiên đấu ghi xong lịch sử và đóng kết nối trước khi đóng store
+			<-closed
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if moves == 0 {
+		t.Fatal("the bot never moved")
+	}
+	if len(illegal) > 0 {
+		t.Fatalf("bot chose %d actions outside LegalActions: %v", len(illegal), illegal)
+	}
+}
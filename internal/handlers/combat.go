@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+// combatLogText trả về combat log dạng văn bản cho client cũ.
+func combatLogText(hits []network.CombatHit) string {
+	lines := make([]string, 0, len(hits))
+	for _, h := range hits {
+		line := fmt.Sprintf("  R%d: %s hits %s for %d", h.Round, h.Attacker, h.Target, h.Damage)
+		if h.Crit {
+			line += " (CRIT!)"
+		}
+		if h.Effect != "" {
+			line += " [" + h.Effect + "]"
+		}
+		lines = append(lines, fmt.Sprintf("%s, HP left: %d", line, h.HPLeft))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sideOf trả về phía sân của người chơi: Player1 ở Home, Player2 ở Away.
+func (gs *GameSession) sideOf(p *models.Player) arena.Side {
+	if p == gs.Player1 {
+		return arena.Home
+	}
+	return arena.Away
+}
+
+func towerTypes(p *models.Player) []string {
+	types := make([]string, 0, len(p.Towers))
+	for _, t := range p.Towers {
+		types = append(types, t.Type)
+	}
+	return types
+}
+
+// combatHits chuyển combat log của engine sang dạng gửi cho client.
+func combatHits(log []engine.LogEntry) []network.CombatHit {
+	hits := make([]network.CombatHit, 0, len(log))
+	for _, e := range log {
+		hits = append(hits, network.CombatHit{
+			Round: e.Round, Attacker: e.Attacker, Target: e.Target, Damage: e.Damage, Crit: e.Crit, HPLeft: e.HPLeft, Effect: e.Effect,
+		})
+	}
+	return hits
+}
+
+// sendAttack báo kết quả trận đánh: người tấn công nhận reply, người phòng thủ
+// và người xem nhận cùng combat log.
+func (gs *GameSession) sendAttack(e engine.Attacked, conn *network.Conn, req network.PDU) {
+	attacker := gs.player(e.Player)
+	defender := gs.opponentOf(attacker)
+	tower := defender.Towers[e.Tower]
+	hits := combatHits(e.Log)
+	result := network.AttackResult{
+		Attacker:  attacker.Username,
+		Defender:  defender.Username,
+		Troop:     e.Troop.Name,
+		Tower:     tower.Type,
+		Index:     e.Tower,
+		Damage:    e.Damage,
+		Crit:      e.Crit,
+		TowerHP:   e.TowerHP,
+		TroopHP:   e.TroopHP,
+		TroopDied: e.TroopDied(),
+		Log:       hits,
+	}
+	text := fmt.Sprintf("⚔️ %s's %s attacks %s's %s\n%s\n💥 %s dealt %d damage to %s",
+		attacker.Username, e.Troop.Name, defender.Username, tower.Type, combatLogText(hits), e.Troop.Name, e.Damage, tower.Type)
+	if e.TroopDied() {
+		text += fmt.Sprintf("\n💀 %s was destroyed by tower fire", e.Troop.Name)
+	}
+	conn.Reply(req, network.MsgAttackResult, text, result)
+	gs.connFor(defender).Send(network.MsgAttackResult, text, result)
+	gs.spectate(network.MsgAttackResult, text, result)
+	gs.recordEvent(network.MsgAttackResult, attacker.Username, result)
+}
+
+// sendHeal báo kết quả hồi máu cho người chơi và người xem.
+func (gs *GameSession) sendHeal(e engine.Healed, conn *network.Conn, req network.PDU) {
+	p := gs.player(e.Player)
+	tower := p.Towers[e.Tower]
+	result := network.HealResult{
+		Player: p.Username,
+		Troop:  e.Troop.Name,
+		Tower:  tower.Type,
+		Index:  e.Tower,
+		Amount: e.Amount,
+		FromHP: e.FromHP,
+		ToHP:   e.ToHP,
+	}
+	conn.Reply(req, network.MsgHealResult,
+		fmt.Sprintf("💖 %s healed your %s by %d HP (from %d ➡ %d)", e.Troop.Name, tower.Type, e.Amount, e.FromHP, e.ToHP),
+		result)
+	gs.spectate(network.MsgHealResult,
+		fmt.Sprintf("💖 %s's %s healed %s by %d HP (from %d ➡ %d)", p.Username, e.Troop.Name, tower.Type, e.Amount, e.FromHP, e.ToHP),
+		result)
+	gs.recordEvent(network.MsgHealResult, p.Username, result)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+)
+
+// StarterCopies là số bản của mỗi card trong bộ sưu tập ban đầu.
+const StarterCopies = 2
+
+// DeckHelp là hướng dẫn lệnh deck cho client văn bản.
+const DeckHelp = "🃏 Type 'deck' to view your deck, or 'deck <card>, <card>, ...' to build a new one."
+
+// ensureCollection cấp bộ sưu tập ban đầu cho người chơi chưa có card nào và
+// dựng deck mặc định nếu deck đã lưu không còn hợp lệ.
+func ensureCollection(p *models.Player, troops []models.Troop, deckSize int) {
+	if len(p.Collection) == 0 {
+		p.Collection = make(map[string]int)
+		for _, t := range troops {
+			p.Collection[t.Name] = StarterCopies
+		}
+	}
+	if _, err := deckTroops(p, p.Deck, troops, deckSize); err != nil {
+		p.Deck = defaultDeck(p, deckSize)
+	}
+}
+
+// defaultDeck lấy lần lượt từng card trong bộ sưu tập (theo tên) cho tới khi đủ deckSize.
+func defaultDeck(p *models.Player, deckSize int) []string {
+	names := make([]string, 0, len(p.Collection))
+	for name := range p.Collection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := make(map[string]int)
+	var deck []string
+	for len(deck) < deckSize {
+		added := false
+		for _, name := range names {
+			if len(deck) < deckSize && used[name] < p.Collection[name] {
+				deck = append(deck, name)
+				used[name]++
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return deck
+}
+
+// deckTroops kiểm tra deck có đúng deckSize card, mỗi card có trong troop.json
+// và không dùng quá số bản người chơi sở hữu; trả về troop tương ứng.
+func deckTroops(p *models.Player, deck []string, troops []models.Troop, deckSize int) ([]models.Troop, error) {
+	if len(deck) != deckSize {
+		return nil, fmt.Errorf("a deck needs exactly %d cards, got %d", deckSize, len(deck))
+	}
+	used := make(map[string]int)
+	cards := make([]models.Troop, 0, len(deck))
+	for _, name := range deck {
+		troop, ok := findTroop(troops, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown card %q", name)
+		}
+		used[troop.Name]++
+		if used[troop.Name] > p.Collection[troop.Name] {
+			return nil, fmt.Errorf("you own only %d %s", p.Collection[troop.Name], troop.Name)
+		}
+		cards = append(cards, troop)
+	}
+	return cards, nil
+}
+
+func findTroop(troops []models.Troop, name string) (models.Troop, bool) {
+	for _, t := range troops {
+		if strings.EqualFold(t.Name, strings.TrimSpace(name)) {
+			return t, true
+		}
+	}
+	return models.Troop{}, false
+}
+
+// dealHand xáo deck của người chơi (chỉ số theo cấp card) bằng rng của trận,
+// chia rules.HandSize card lên tay và để phần còn lại làm hàng chờ rút bài.
+func dealHand(p *models.Player, troops []models.Troop, curves models.LevelCurves, rules models.Ruleset, rng *rand.Rand) {
+	ensureCollection(p, troops, rules.DeckSize)
+	cards, err := deckTroops(p, p.Deck, troops, rules.DeckSize)
+	if err != nil {
+		// Bộ sưu tập quá nhỏ để dựng đủ deck: chơi với những card đang có
+		cards = nil
+		for _, name := range p.Deck {
+			if t, ok := findTroop(troops, name); ok {
+				cards = append(cards, t)
+			}
+		}
+	}
+	if len(rules.AllowedCards) > 0 {
+		cards = allowedDeck(cards, troops, rules)
+	}
+	for i := range cards {
+		cards[i] = leveledTroop(p, cards[i], curves)
+	}
+	rng.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+
+	n := rules.HandSize
+	if n > len(cards) {
+		n = len(cards)
+	}
+	p.Troops = append([]models.Troop{}, cards[:n]...)
+	p.DrawPile = append([]models.Troop{}, cards[n:]...)
+}
+
+// allowedDeck bỏ các card không được phép trong trận và bù bằng lần lượt
+// từng card được phép cho tới khi đủ rules.DeckSize.
+func allowedDeck(cards, troops []models.Troop, rules models.Ruleset) []models.Troop {
+	var allowed []models.Troop
+	for _, name := range rules.AllowedCards {
+		if t, ok := findTroop(troops, name); ok {
+			allowed = append(allowed, t)
+		}
+	}
+	if len(allowed) == 0 {
+		return cards
+	}
+
+	var deck []models.Troop
+	for _, c := range cards {
+		if _, ok := findTroop(allowed, c.Name); ok {
+			deck = append(deck, c)
+		}
+	}
+	for i := 0; len(deck) < rules.DeckSize; i++ {
+		deck = append(deck, allowed[i%len(allowed)])
+	}
+	return deck
+}
+
+// nextCard là card sẽ vào tay sau lần chơi kế tiếp.
+func nextCard(p *models.Player) (models.Troop, bool) {
+	if len(p.DrawPile) == 0 {
+		return models.Troop{}, false
+	}
+	return p.DrawPile[0], true
+}
+
+// handText liệt kê bài trên tay kèm card kế tiếp.
+func handText(p *models.Player) string {
+	text := ""
+	for i, t := range p.Troops {
+		text += fmt.Sprintf("%d. %s (ATK: %d, DEF: %d, Mana: %d)\n", i+1, t.Name, t.ATK, t.DEF, t.Mana)
+	}
+	if next, ok := nextCard(p); ok {
+		text += fmt.Sprintf("Next: %s\n", next.Name)
+	}
+	return text
+}
+
+// HandleDeckCommand xử lý lệnh xem và lưu deck ngoài trận; trả về false nếu
+// PDU không phải lệnh deck.
+func HandleDeckCommand(conn *network.Conn, pdu network.PDU, player *models.Player, store storage.PlayerStore, rules models.Ruleset) bool {
+	var cards []string
+	switch {
+	case pdu.Type == network.MsgDeck:
+	case pdu.Type == network.MsgSaveDeck:
+		var req network.SaveDeck
+		if err := pdu.DecodeData(&req); err != nil {
+			conn.Reply(pdu, "error", "❌ Invalid deck request.", nil)
+			return true
+		}
+		cards = req.Cards
+		if cards == nil {
+			cards = []string{}
+		}
+	default:
+		fields := strings.Fields(pdu.Payload)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "deck") {
+			return false
+		}
+		rest := strings.TrimSpace(strings.TrimSpace(pdu.Payload)[len(fields[0]):])
+		if rest != "" {
+			cards = strings.FieldsFunc(rest, func(r rune) bool { return r == ',' })
+		}
+	}
+
+	ensureCollection(player, rules.Troops, rules.DeckSize)
+
+	if cards != nil {
+		deck, err := deckTroops(player, cards, rules.Troops, rules.DeckSize)
+		if err != nil {
+			conn.Reply(pdu, "error", "❌ Invalid deck: "+err.Error()+".", nil)
+			return true
+		}
+		names := make([]string, 0, len(deck))
+		for _, t := range deck {
+			names = append(names, t.Name)
+		}
+		player.Deck = names
+		if err := store.Put(player); err != nil {
+			fmt.Printf("❌ Failed to save deck for %s: %v\n", player.Username, err)
+			conn.Reply(pdu, "error", "❌ Failed to save deck.", nil)
+			return true
+		}
+	}
+
+	info := network.DeckInfo{Deck: player.Deck, Collection: player.Collection}
+	text := "🃏 Your deck: " + strings.Join(player.Deck, ", ") + "\n📚 Collection:"
+	names := make([]string, 0, len(player.Collection))
+	for name := range player.Collection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		text += fmt.Sprintf(" %s x%d", name, player.Collection[name])
+	}
+	if cards != nil {
+		text = "✅ Deck saved!\n" + text
+	}
+	conn.Reply(pdu, network.MsgDeck, text, info)
+	return true
+}
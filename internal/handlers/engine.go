@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+// engineState chụp trạng thái trận cho package engine. Slice của người chơi
+// được dùng chung vì engine sao chép trước khi sửa.
+func (gs *GameSession) engineState() engine.State {
+	s := engine.State{
+		Rules:  gs.Rules,
+		Arena:  gs.arena,
+		Turn:   gs.index(gs.TurnOwner),
+		RNG:    gs.engineRNG,
+		Over:   gs.GameOver,
+		Winner: -1,
+	}
+	for i, p := range []*models.Player{gs.Player1, gs.Player2} {
+		s.Players[i] = engine.PlayerState{
+			Name:      p.Username,
+			Towers:    p.Towers,
+			Hand:      p.Troops,
+			DrawPile:  p.DrawPile,
+			Mana:      p.Mana,
+			CritsLeft: p.CritsLeft,
+		}
+	}
+	return s
+}
+
+// applyState ghi trạng thái engine trả về vào người chơi của trận.
+func (gs *GameSession) applyState(s engine.State) {
+	for i, p := range []*models.Player{gs.Player1, gs.Player2} {
+		ps := s.Players[i]
+		p.Towers, p.Troops, p.DrawPile = ps.Towers, ps.Hand, ps.DrawPile
+		p.Mana, p.CritsLeft = ps.Mana, ps.CritsLeft
+	}
+	gs.TurnOwner = gs.player(s.Turn)
+	gs.engineRNG = s.RNG
+}
+
+// act thực hiện action của người chơi đang có lượt. Hành động không hợp lệ
+// vẫn kết thúc lượt, như khi chọn sai trong menu.
+func (gs *GameSession) act(p *models.Player, conn *network.Conn, req network.PDU, action engine.Action) {
+	next, events, err := engine.Apply(gs.engineState(), action)
+	if err != nil {
+		conn.Reply(req, "error", actionError(err), nil)
+		gs.endTurn(p)
+		return
+	}
+	gs.applyState(next)
+	gs.render(events, conn, req)
+}
+
+// render chuyển các sự kiện của engine thành message cho người chơi, người
+// xem, lịch sử đấu và replay. conn và req là kết nối và PDU của người vừa
+// hành động.
+func (gs *GameSession) render(events []engine.Event, conn *network.Conn, req network.PDU) {
+	for _, ev := range events {
+		switch e := ev.(type) {
+		case engine.Attacked:
+			gs.sendAttack(e, conn, req)
+		case engine.Healed:
+			gs.sendHeal(e, conn, req)
+		case engine.CardPlayed:
+			if !gs.practice() {
+				gainCardExp(gs.player(e.Player), e.Card)
+			}
+		case engine.CardDrawn:
+			gs.connFor(gs.player(e.Player)).SendPDU("event", fmt.Sprintf("✨ %s joins your hand!", e.Card.Name))
+		case engine.TowerDestroyed:
+			owner := gs.player(e.Owner)
+			gs.announceTowerDestroyed(gs.opponentOf(owner), owner, e.Tower)
+		case engine.Passed:
+			gs.Broadcast(fmt.Sprintf("⏭️ %s passed.", gs.player(e.Player).Username))
+		case engine.TurnStarted:
+			gs.startTurn()
+		case engine.GameEnded:
+			gs.endGame(e)
+		}
+	}
+}
+
+// actionError là thông báo cho người chơi khi engine từ chối hành động.
+func actionError(err error) string {
+	switch {
+	case errors.Is(err, engine.ErrInvalidCard):
+		return "❌ Invalid troop selection."
+	case errors.Is(err, engine.ErrNotEnoughMana):
+		return "❌ Not enough mana."
+	case errors.Is(err, engine.ErrNoCrits):
+		return "❌ No CRITs left."
+	case errors.Is(err, engine.ErrInvalidTower):
+		return "❌ Invalid tower selection."
+	case errors.Is(err, engine.ErrNothingToHeal):
+		return "⚠️ No towers to heal."
+	case errors.Is(err, engine.ErrFullHP):
+		return "⚠️ Tower already at full HP."
+	case errors.Is(err, engine.ErrNotYourTurn):
+		return "⏳ Please wait for your turn."
+	}
+	return "❌ " + err.Error() + "."
+}
+
+// announceTowerDestroyed thông báo tower index của defender đã bị phá.
+func (gs *GameSession) announceTowerDestroyed(attacker, defender *models.Player, index int) {
+	tower := defender.Towers[index]
+	destroyed := network.TowerDestroyed{Owner: defender.Username, Tower: tower.Type, Index: index}
+	gs.BroadcastEvent(network.MsgTowerDestroyed, fmt.Sprintf("🏰 %s destroyed!", tower.Type), destroyed)
+	gs.recordEvent(network.MsgTowerDestroyed, attacker.Username, destroyed)
+}
+
+// endGame thông báo kết quả trận, trao thưởng theo cách kết thúc và dừng vòng
+// lặp trận đấu.
+func (gs *GameSession) endGame(e engine.GameEnded) {
+	gs.GameOver = true
+	var winner, loser *models.Player
+	if e.Winner >= 0 {
+		winner = gs.player(e.Winner)
+		loser = gs.opponentOf(winner)
+	}
+
+	switch {
+	case e.Reason == engine.ReasonKing:
+		gs.announceGameOver(fmt.Sprintf("🎉 %s wins by destroying the King Tower!", winner.Username),
+			network.GameOver{Winner: winner.Username, Reason: e.Reason})
+		gs.reward(winner, gs.Rules.Rewards.KingWin)
+		gs.reward(loser, gs.Rules.Rewards.KingLoss)
+	case e.Reason == engine.ReasonSurrender:
+		gs.announceGameOver(fmt.Sprintf("🏳️ %s surrendered. %s wins!", loser.Username, winner.Username),
+			network.GameOver{Winner: winner.Username, Reason: e.Reason})
+		gs.reward(winner, gs.Rules.Rewards.ForfeitWin)
+	case winner != nil:
+		gs.Broadcast("⏰ Time is up! Calculating results...")
+		gs.announceGameOver(fmt.Sprintf("🎉 %s wins (%d towers destroyed)!", winner.Username, e.Destroyed[e.Winner]),
+			network.GameOver{Winner: winner.Username, Reason: e.Reason})
+		gs.reward(winner, gs.Rules.Rewards.TimeWin)
+		gs.reward(loser, gs.Rules.Rewards.TimeLoss)
+	default:
+		gs.Broadcast("⏰ Time is up! Calculating results...")
+		gs.announceGameOver("🤝 It's a draw!", network.GameOver{Reason: e.Reason})
+		gs.reward(gs.Player1, gs.Rules.Rewards.Draw)
+		gs.reward(gs.Player2, gs.Rules.Rewards.Draw)
+	}
+	gs.signalGameOver()
+}
+
+// index là số thứ tự của người chơi trong engine: 0 với Player1, 1 với Player2.
+func (gs *GameSession) index(p *models.Player) int {
+	if p == gs.Player1 {
+		return 0
+	}
+	return 1
+}
+
+func (gs *GameSession) player(i int) *models.Player {
+	if i == 0 {
+		return gs.Player1
+	}
+	return gs.Player2
+}
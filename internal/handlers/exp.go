@@ -5,6 +5,12 @@ import (
 	"net-centric-clash-royale/internal/models"
 )
 
+// reward trao EXP và gold của một kết quả trận cho người chơi.
+func reward(player *models.Player, r models.Reward) {
+	AddExp(player, r.EXP)
+	AddGold(player, r.Gold)
+}
+
 func AddExp(player *models.Player, expGain int) {
 	player.EXP += expGain
 	nextLevelExp := 100 + (player.Level-1)*10
@@ -13,12 +19,5 @@ func AddExp(player *models.Player, expGain int) {
 		player.EXP -= nextLevelExp
 		player.Level++
 		fmt.Printf("🌟 %s leveled up! Now level %d\n", player.Username, player.Level)
-
-		// Tăng chỉ số Tower theo cấp độ (10%)
-		for i := range player.Towers {
-			player.Towers[i].HP = int(float64(player.Towers[i].HP) * 1.1)
-			player.Towers[i].ATK = int(float64(player.Towers[i].ATK) * 1.1)
-			player.Towers[i].DEF = int(float64(player.Towers[i].DEF) * 1.1)
-		}
 	}
 }
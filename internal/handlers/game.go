@@ -3,39 +3,99 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math/rand"
-	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/engine"
 	"net-centric-clash-royale/internal/models"
 	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/replay"
+	"net-centric-clash-royale/internal/storage"
 	"net-centric-clash-royale/internal/utils"
 )
 
+// turnStage là bước hiện tại trong menu của người chơi đang có lượt.
+type turnStage int
+
 const (
-	QueenHealAmount = 200
-	QueenMaxHealHP  = 1000
-	MaxCritsPerGame = 5
+	stageMenu   turnStage = iota // chờ chọn Attack / Show Status
+	stageTroop                   // chờ chọn troop
+	stageCrit                    // chờ trả lời có dùng CRIT không
+	stageTarget                  // chờ chọn tower mục tiêu
 )
 
+// GameSession holds the state of one match. All fields below are owned by the
+// session's loop goroutine (see run); other goroutines talk to it through the
+// inputs and reconnects channels only.
 type GameSession struct {
 	Player1      *models.Player
 	Player2      *models.Player
-	Conn1        net.Conn
-	Conn2        net.Conn
+	Conn1        *network.Conn
+	Conn2        *network.Conn
 	GameOver     bool
 	TurnOwner    *models.Player
-	Mutex        *sync.Mutex
 	GameTimer    *GameTimer
+	Mode         models.GameMode
 	IsTimedGame  bool
+	Store        storage.PlayerStore
+	Rules        models.Ruleset
 	gameOverChan chan bool
+	matchID      int64                // id trong lịch sử đấu, 0 nếu store không lưu lịch sử
+	events       []storage.MatchEvent // sự kiện chưa ghi vào lịch sử, xem recordEvent
+	Seed         int64                // seed của rng, được lưu trong lịch sử đấu và replay
+	rng          *rand.Rand           // mọi yếu tố ngẫu nhiên của trận đều lấy từ đây
+	engineRNG    uint64               // trạng thái RNG của engine, khởi tạo từ rng
+	replay       *replay.Recorder     // nil nếu không tạo được file replay
+	bot          *botPlayer           // đối thủ do server điều khiển, nil nếu cả hai là người
+
+	inputs     chan playerInput
+	reconnects chan reconnectRequest
+	done       chan struct{} // đóng khi vòng lặp kết thúc
+	readers    sync.WaitGroup
+
+	// Lựa chọn dở dang của người chơi đang có lượt
+	stage      turnStage
+	troopIndex int
+	useCrit    bool
+
+	// Hạn chót để kết nối lại của những người chơi đang mất kết nối
+	disconnected map[*models.Player]time.Time
+
+	// Người xem trận; spectatorCount để LiveMatches đọc từ goroutine khác
+	spectators      []*spectator
+	spectatorCount  atomic.Int32
+	spectates       chan *spectator
+	spectatorInputs chan spectatorInput
+	spectatorLeaves chan *spectator
+
+	arena *arena.Arena
+
+	// Trạng thái mô phỏng của chế độ real-time
+	tick       uint64
+	units      []*unit
+	nextUnitID int
+	towerFx    [2][]engine.Effects // hiệu ứng đang tác động lên tower của mỗi side
+}
+
+// StartGameSession initializes a game between two players under rules
+func StartGameSession(p1, p2 *models.Player, conn1, conn2 *network.Conn, mode models.GameMode, store storage.PlayerStore, rules models.Ruleset) chan bool {
+	return StartSeededGameSession(p1, p2, conn1, conn2, mode, store, rules, time.Now().UnixNano())
 }
 
-// StartGameSession initializes a game between two players
-func StartGameSession(p1, p2 *models.Player, conn1, conn2 net.Conn, isTimedGame bool) chan bool {
+// StartSeededGameSession initializes a game whose randomness (hands, draws,
+// tower crits) comes from seed, so that the same seed and the same inputs
+// play out the same match again.
+func StartSeededGameSession(p1, p2 *models.Player, conn1, conn2 *network.Conn, mode models.GameMode, store storage.PlayerStore, rules models.Ruleset, seed int64) chan bool {
+	return startGameSession(p1, p2, conn1, conn2, mode, store, rules, seed, nil)
+}
 
+// startGameSession bắt đầu trận; b khác nil nếu p2 là bot.
+func startGameSession(p1, p2 *models.Player, conn1, conn2 *network.Conn, mode models.GameMode, store storage.PlayerStore, rules models.Ruleset, seed int64, b *botPlayer) chan bool {
 	session := &GameSession{
 		Player1:      p1,
 		Player2:      p2,
@@ -43,70 +103,73 @@ func StartGameSession(p1, p2 *models.Player, conn1, conn2 net.Conn, isTimedGame
 		Conn2:        conn2,
 		GameOver:     false,
 		TurnOwner:    p1,
-		Mutex:        &sync.Mutex{},
-		IsTimedGame:  isTimedGame,
+		Mode:         mode,
+		IsTimedGame:  mode.Timed(),
+		Store:        store,
+		Rules:        rules,
+		Seed:         seed,
+		rng:          rand.New(rand.NewSource(seed)),
 		gameOverChan: make(chan bool),
+		inputs:       make(chan playerInput),
+		reconnects:   make(chan reconnectRequest),
+		done:         make(chan struct{}),
+		disconnected: make(map[*models.Player]time.Time),
+
+		spectates:       make(chan *spectator),
+		spectatorInputs: make(chan spectatorInput),
+		spectatorLeaves: make(chan *spectator),
+
+		bot: b,
 	}
 
-	troops, err := utils.LoadTroopsFromFile("data/troop.json")
-	if err != nil || len(troops) < 3 {
-		errMsg := "❌ Server error: cannot load or insufficient troop data."
-		network.SendPDU(conn1, "error", errMsg)
-		network.SendPDU(conn2, "error", errMsg)
+	troops := rules.Troops
+	if len(troops) < 3 {
+		errMsg := "❌ Server error: insufficient troop data."
+		conn1.SendPDU("error", errMsg)
+		conn2.SendPDU("error", errMsg)
 		conn1.Close()
 		conn2.Close()
 		close(session.gameOverChan)
 		return session.gameOverChan
 	}
 
-	p1.Troops = getRandomTroops(troops, 3)
-	p2.Troops = getRandomTroops(troops, 3)
+	// Chỉ số theo cấp của card và tower được chốt lúc bắt đầu trận
+	curves := rules.Levels
+	dealHand(p1, troops, curves, rules, session.rng)
+	dealHand(p2, troops, curves, rules, session.rng)
+	session.engineRNG = session.rng.Uint64()
 	p1.Towers, _ = utils.LoadPlayerTowers()
 	p2.Towers, _ = utils.LoadPlayerTowers()
-	p1.CritsLeft = MaxCritsPerGame
-	p2.CritsLeft = MaxCritsPerGame
-
-	session.Broadcast("🔥 Match found! " + p1.Username + " vs " + p2.Username)
-	session.Broadcast("🎯 " + p1.Username + " will go first!")
+	applyTowerLevels(p1, curves)
+	applyTowerLevels(p2, curves)
+	p1.CritsLeft = rules.MaxCritsPerGame
+	p2.CritsLeft = rules.MaxCritsPerGame
+	p1.Mana = rules.StartMana
+	p2.Mana = rules.StartMana
+	session.arena = arena.New(towerTypes(p1), towerTypes(p2))
+	registerSession(session)
+	session.startRecording()
+	session.startReplay()
+
+	found := network.MatchFound{Player1: p1.Username, Player2: p2.Username, Timed: session.IsTimedGame, Mode: mode.String()}
+	if mode != models.ModeRealtime {
+		found.FirstTurn = p1.Username
+	}
+	session.BroadcastEvent(network.MsgMatchFound, "🔥 Match found! "+p1.Username+" vs "+p2.Username, found)
+	if mode != models.ModeRealtime {
+		session.Broadcast("🎯 " + p1.Username + " will go first!")
+	}
 	if session.IsTimedGame {
-		session.GameTimer = NewGameTimer()
+		session.GameTimer = NewGameTimer(rules.GameDuration())
 		session.GameTimer.Start()
-		go session.watchTimer()
 	} else {
 		session.Broadcast("This is an untimed game.")
 	}
 
-	go func() {
-		for !session.GameOver {
-			session.TakeTurn()
-		}
-		// Wait briefly to ensure all PDUs are sent
-		time.Sleep(500 * time.Millisecond)
-		session.askRematch()
-	}()
+	go session.run()
 	return session.gameOverChan
 }
 
-func (gs *GameSession) watchTimer() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
-		if gs.GameOver {
-			return
-		}
-		if gs.GameTimer.IsTimeUp() {
-			gs.Mutex.Lock()
-			if !gs.GameOver {
-				gs.GameOver = true
-				gs.endGameByTime()
-				gs.signalGameOver()
-			}
-			gs.Mutex.Unlock()
-			return
-		}
-	}
-}
-
 func (gs *GameSession) signalGameOver() {
 	if !gs.GameOver {
 		gs.GameOver = true
@@ -119,10 +182,33 @@ func (gs *GameSession) signalGameOver() {
 	}
 }
 
+// saveProgress lưu EXP, level, gold, rating và tiến trình card / tower của
+// cả hai người chơi sau khi trận đấu kết thúc. Chỉ các field đó được ghi: bản
+// trong store có thể đã đổi trong lúc đấu (deck, upgrade từ phiên khác), còn
+// tower HP, bài trên tay và mana chỉ có nghĩa trong trận. Trận luyện tập với
+// bot không lưu gì.
+func (gs *GameSession) saveProgress() {
+	if gs.practice() {
+		return
+	}
+	for _, p := range []*models.Player{gs.Player1, gs.Player2} {
+		gainTowerExp(p)
+		err := gs.Store.Update(p.Username, func(stored *models.Player) error {
+			stored.EXP, stored.Level, stored.Gold, stored.Rating = p.EXP, p.Level, p.Gold, p.Rating
+			stored.CardLevels = maps.Clone(p.CardLevels)
+			stored.TowerLevels = maps.Clone(p.TowerLevels)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("❌ Failed to save progress for %s: %v\n", p.Username, err)
+		}
+	}
+}
+
 func (gs *GameSession) askRematch() {
-	ask := func(conn net.Conn) bool {
-		network.SendPDU(conn, "menu", "🔁 Do you want to play again?\n1. Yes\n2. No")
-		pdu, err := network.ReadPDU(conn)
+	ask := func(conn *network.Conn) bool {
+		conn.SendPDU("menu", "🔁 Do you want to play again?\n1. Yes\n2. No")
+		pdu, err := conn.ReadPDU()
 		if err != nil {
 			return false
 		}
@@ -133,269 +219,249 @@ func (gs *GameSession) askRematch() {
 	playAgain2 := ask(gs.Conn2)
 
 	if playAgain1 && playAgain2 {
-		network.SendPDU(gs.Conn1, "info", "🔄 Restarting game...")
-		network.SendPDU(gs.Conn2, "info", "🔄 Restarting game...")
+		gs.Conn1.SendPDU("info", "🔄 Restarting game...")
+		gs.Conn2.SendPDU("info", "🔄 Restarting game...")
 
-		mode1 := getPlayerMode(gs.Conn1)
-		mode2 := getPlayerMode(gs.Conn2)
-		mode := mode1 && mode2
+		// Trận luyện tập chơi lại với cùng bot và cùng chế độ, không hỏi lại
+		if gs.practice() {
+			go startGameSession(gs.Player1, gs.Player2, gs.Conn1, gs.Conn2, gs.Mode, gs.Store, gs.Rules, time.Now().UnixNano(), gs.bot)
+			return
+		}
+
+		// Hai người chọn khác nhau thì chơi Untimed Game
+		mode := getPlayerMode(gs.Conn1, gs.Rules)
+		if getPlayerMode(gs.Conn2, gs.Rules) != mode {
+			mode = models.ModeUntimed
+		}
 
-		go StartGameSession(gs.Player1, gs.Player2, gs.Conn1, gs.Conn2, mode)
+		go StartGameSession(gs.Player1, gs.Player2, gs.Conn1, gs.Conn2, mode, gs.Store, gs.Rules)
 	} else {
-		network.SendPDU(gs.Conn1, "info", "👋 Game over. Thank you for playing!")
-		network.SendPDU(gs.Conn2, "info", "👋 Game over. Thank you for playing!")
+		gs.Conn1.SendPDU("info", "👋 Game over. Thank you for playing!")
+		gs.Conn2.SendPDU("info", "👋 Game over. Thank you for playing!")
 		gs.Conn1.Close()
 		gs.Conn2.Close()
 	}
 }
 
-func getPlayerMode(conn net.Conn) bool {
-	network.SendPDU(conn, "menu", "Choose game mode:\n1. Timed Game (3 minutes)\n2. Untimed Game\nEnter 1 or 2:")
-	pdu, err := network.ReadPDU(conn)
-	if err != nil {
-		return false
+// getPlayerMode hỏi chế độ cho trận đấu lại; chế độ client không hỗ trợ thì
+// hỏi lại, lựa chọn không hợp lệ thì chơi Untimed Game.
+func getPlayerMode(conn *network.Conn, rules models.Ruleset) models.GameMode {
+	for {
+		conn.SendPDU("menu", ModeMenu(rules))
+		pdu, err := conn.ReadPDU()
+		if err != nil {
+			return models.ModeUntimed
+		}
+		mode, _ := models.ParseModeChoice(pdu.Payload)
+		if ModeSupported(conn, mode) {
+			return mode
+		}
+		conn.Reply(pdu, "error", "❗ Your client does not support this game mode.", nil)
 	}
-	return strings.TrimSpace(pdu.Payload) == "1"
 }
 
-func (gs *GameSession) TakeTurn() {
-	if gs.IsTimedGame && gs.GameTimer.IsTimeUp() && !gs.GameOver {
-		gs.Mutex.Lock()
-		if !gs.GameOver {
-			gs.GameOver = true
-			gs.endGameByTime()
-			gs.signalGameOver()
-		}
-		gs.Mutex.Unlock()
-		return
-	}
-
-	var conn net.Conn
-	var active, opponent *models.Player
-	if gs.TurnOwner == gs.Player1 {
-		conn = gs.Conn1
-		active = gs.Player1
-		opponent = gs.Player2
-	} else {
-		conn = gs.Conn2
-		active = gs.Player2
-		opponent = gs.Player1
-	}
+// startTurn gửi menu cho người chơi đang có lượt.
+func (gs *GameSession) startTurn() {
+	active := gs.TurnOwner
+	gs.stage = stageMenu
 
+	turn := network.TurnStarted{Player: active.Username}
 	menu := fmt.Sprintf("🎯 Your turn, %s", active.Username)
 	if gs.IsTimedGame {
+		turn.TimeLeftSec = int(gs.GameTimer.TimeRemaining().Seconds())
 		menu += fmt.Sprintf(" (Time Left: %s)", gs.GameTimer.FormattedTimeRemaining())
 	}
-	menu += "\n1. Attack Tower\n2. Show Status"
-	network.SendPDU(conn, "menu", menu)
-
-	pdu, err := network.ReadPDU(conn)
-	if err != nil {
-		gs.signalGameOver()
-		network.SendPDU(conn, "error", "⚠️ Connection lost.")
-		return
+	gs.connFor(gs.Player1).Send(network.MsgTurnStarted, "", turn)
+	gs.connFor(gs.Player2).Send(network.MsgTurnStarted, "", turn)
+	// Người chơi đã thấy lượt qua menu; người xem cần văn bản riêng
+	gs.spectate(network.MsgTurnStarted, fmt.Sprintf("🎯 %s's turn", active.Username), turn)
+	menu += "\n1. Attack Tower\n2. Show Status\n3. Pass\n4. Surrender"
+	gs.connFor(active).SendPDU("menu", menu)
+	if gs.isBot(active) {
+		gs.bot.yourTurn(gs.engineState())
 	}
-	choice := strings.TrimSpace(pdu.Payload)
+}
 
-	switch choice {
-	case "1":
-		gs.HandleAttack(active, opponent, conn)
-	case "2":
-		showStatus(conn, active)
-	default:
-		network.SendPDU(conn, "error", "❗ Invalid choice.")
-	}
+// handleTurnInput xử lý một PDU của người chơi đang có lượt theo bước hiện tại
+// và chuyển lựa chọn đã hoàn tất thành action của engine. Lượt chỉ kết thúc
+// khi action được thực hiện hoặc lựa chọn không hợp lệ.
+func (gs *GameSession) handleTurnInput(active *models.Player, conn *network.Conn, pdu network.PDU) {
+	me := gs.index(active)
+	choice := strings.TrimSpace(pdu.Payload)
 
-	if active.Mana >= 10 && len(active.Troops) < 3 {
-		troops, _ := utils.LoadTroopsFromFile("data/troop.json")
-		for {
-			newTroop := getRandomTroops(troops, 1)[0]
-			// Only 1 Queen
-			if strings.ToLower(newTroop.Name) == "queen" && hasTroop(active.Troops, "queen") {
-				continue // skip if Queen already exists
+	switch gs.stage {
+	case stageMenu:
+		switch {
+		case pdu.Type == network.MsgAttackRequest:
+			gs.handleAttackRequest(active, conn, pdu)
+			return
+		case choice == "1":
+			if gs.promptTroop(active, conn) {
+				return
 			}
-			active.Troops = append(active.Troops, newTroop)
-			network.SendPDU(conn, "event", fmt.Sprintf("✨ %s joins your hand!", newTroop.Name))
-			break
-		}
-	}
-	if !gs.GameOver {
-		if gs.TurnOwner == gs.Player1 {
-			gs.TurnOwner = gs.Player2
-		} else {
-			gs.TurnOwner = gs.Player1
+		case choice == "2":
+			showStatus(conn, active)
+		case choice == "3":
+			gs.act(active, conn, pdu, engine.Pass{Player: me})
+			return
+		case choice == "4":
+			gs.act(active, conn, pdu, engine.Surrender{Player: me})
+			return
+		default:
+			conn.SendPDU("error", "❗ Invalid choice.")
 		}
+	case stageTroop:
+		gs.selectTroop(active, conn, pdu)
+		return
+	case stageCrit:
+		gs.useCrit = choice == "1"
+		gs.promptTarget(active, conn)
+		return
+	case stageTarget:
+		gs.act(active, conn, pdu, attackAction(me, gs.troopIndex, parseIndex(pdu.Payload)-1, gs.useCrit))
+		return
 	}
+	gs.endTurn(active)
 }
 
-func hasTroop(troops []models.Troop, name string) bool {
-	for _, t := range troops {
-		if strings.ToLower(t.Name) == strings.ToLower(name) {
-			return true
-		}
+// endTurn chuyển lượt cho đối thủ.
+func (gs *GameSession) endTurn(active *models.Player) {
+	if gs.GameOver {
+		return
 	}
-	return false
+	gs.TurnOwner = gs.opponentOf(active)
+	gs.startTurn()
 }
 
 func (gs *GameSession) Broadcast(msg string) {
-	network.SendPDU(gs.Conn1, "broadcast", msg)
-	network.SendPDU(gs.Conn2, "broadcast", msg)
+	gs.connFor(gs.Player1).SendPDU("broadcast", msg)
+	gs.connFor(gs.Player2).SendPDU("broadcast", msg)
+	gs.spectate("broadcast", msg, nil)
+}
+
+// BroadcastEvent gửi một message có kiểu tới cả hai người chơi và người xem.
+func (gs *GameSession) BroadcastEvent(pduType, text string, data interface{}) {
+	gs.connFor(gs.Player1).Send(pduType, text, data)
+	gs.connFor(gs.Player2).Send(pduType, text, data)
+	gs.spectate(pduType, text, data)
 }
 
-func (gs *GameSession) HandleAttack(attacker, defender *models.Player, conn net.Conn) {
+// promptTroop gửi danh sách troop; trả về false nếu không có troop nào.
+func (gs *GameSession) promptTroop(attacker *models.Player, conn *network.Conn) bool {
 	if len(attacker.Troops) == 0 {
-		network.SendPDU(conn, "error", "❌ You have no troops to attack with.")
-		return
+		conn.SendPDU("error", "❌ You have no troops to attack with.")
+		return false
 	}
 
-	troopList := "Choose a troop to attack with:\n"
-	for i, t := range attacker.Troops {
-		troopList += fmt.Sprintf("%d. %s (ATK: %d, DEF: %d, Mana: %d)\n", i+1, t.Name, t.ATK, t.DEF, t.Mana)
-	}
-	network.SendPDU(conn, "select", troopList)
-	pdu, _ := network.ReadPDU(conn)
+	conn.SendPDU("select", "Choose a troop to attack with:\n"+handText(attacker))
+	gs.stage = stageTroop
+	return true
+}
+
+// selectTroop xử lý troop được chọn: troop dùng ngay được thực hiện luôn,
+// troop ra trận chờ chọn CRIT và tower mục tiêu.
+func (gs *GameSession) selectTroop(attacker *models.Player, conn *network.Conn, pdu network.PDU) {
+	me := gs.index(attacker)
 	troopIndex := parseIndex(pdu.Payload) - 1
-	if troopIndex < 0 || troopIndex >= len(attacker.Troops) {
-		network.SendPDU(conn, "error", "❌ Invalid troop selection.")
-		return
-	}
-	troop := attacker.Troops[troopIndex]
-	if attacker.Mana < troop.Mana {
-		network.SendPDU(conn, "error", "❌ Not enough mana.")
+	if err := engine.CheckCard(gs.engineState(), me, troopIndex); err != nil {
+		conn.SendPDU("error", actionError(err))
+		gs.endTurn(attacker)
 		return
 	}
 
-	// Troop queen
-	if strings.ToLower(troop.Name) == "queen" {
-		var lowest *models.Tower
-		for i := range attacker.Towers {
-			t := &attacker.Towers[i]
-			if t.HP > 0 && (lowest == nil || t.HP < lowest.HP) {
-				lowest = t
-			}
-		}
-		if lowest != nil {
-			oldHP := lowest.HP
-			heal := QueenHealAmount
-			if oldHP+heal > QueenMaxHealHP {
-				heal = QueenMaxHealHP - oldHP
-			}
-			if heal > 0 {
-				lowest.HP += heal
-				network.SendPDU(conn, "result", fmt.Sprintf("💖 Queen healed your %s by %d HP (from %d ➡ %d)", lowest.Type, heal, oldHP, lowest.HP))
-			} else {
-				network.SendPDU(conn, "event", "⚠️ Tower already at full HP.")
-			}
-		} else {
-			network.SendPDU(conn, "event", "⚠️ No towers to heal.")
-		}
+	// Troop có ability dùng ngay (vd. Queen hồi máu) không ra trận
+	if engine.IsCast(attacker.Troops[troopIndex]) {
+		gs.act(attacker, conn, pdu, engine.Heal{Player: me, Card: troopIndex})
 		return
 	}
 
+	gs.troopIndex = troopIndex
+	gs.useCrit = false
 	// Crit 20%
-	useCrit := false
 	if attacker.CritsLeft > 0 {
-		network.SendPDU(conn, "select", fmt.Sprintf("⚡ You have %d CRIT(s). Use one?\n1. Yes\n2. No", attacker.CritsLeft))
-		pdu, _ := network.ReadPDU(conn)
-		if strings.TrimSpace(pdu.Payload) == "1" {
-			useCrit = true
-			attacker.CritsLeft--
-		}
+		conn.SendPDU("select", fmt.Sprintf("⚡ You have %d CRIT(s). Use one?\n1. Yes\n2. No", attacker.CritsLeft))
+		gs.stage = stageCrit
+		return
 	}
+	gs.promptTarget(attacker, conn)
+}
 
-	guardsDown := true
-	for _, t := range defender.Towers {
-		if t.Type == "Guard Tower" && t.HP > 0 {
-			guardsDown = false
-		}
-	}
+func (gs *GameSession) promptTarget(attacker *models.Player, conn *network.Conn) {
+	defender := gs.opponentOf(attacker)
 	targetList := "Choose tower to attack:\n"
-	validIndices := []int{}
-	for i, t := range defender.Towers {
-		if t.HP <= 0 {
-			continue
-		}
-		if t.Type == "King Tower" && !guardsDown {
-			continue
-		}
+	for _, i := range gs.engineState().Targets(gs.index(attacker)) {
+		t := defender.Towers[i]
 		targetList += fmt.Sprintf("%d. %s (HP: %d)\n", i+1, t.Type, t.HP)
-		validIndices = append(validIndices, i)
 	}
-	network.SendPDU(conn, "select", targetList)
-	pdu, _ = network.ReadPDU(conn)
-	targetIndex := parseIndex(pdu.Payload) - 1
-	if targetIndex < 0 || targetIndex >= len(defender.Towers) {
-		network.SendPDU(conn, "error", "❌ Invalid tower selection.")
+	conn.SendPDU("select", targetList)
+	gs.stage = stageTarget
+}
+
+// handleAttackRequest thực hiện một AttackRequest có kiểu, không qua menu.
+func (gs *GameSession) handleAttackRequest(attacker *models.Player, conn *network.Conn, pdu network.PDU) {
+	var req network.AttackRequest
+	if err := pdu.DecodeData(&req); err != nil {
+		conn.Reply(pdu, "error", "❌ Invalid attack request.", nil)
+		gs.endTurn(attacker)
 		return
 	}
-	tower := &defender.Towers[targetIndex]
-	fmt.Printf("DEBUG: %s attacking tower %s (DEF: %d)\n", attacker.Username, tower.Type, tower.DEF)
-	damage := utils.CalculateDamage(troop.ATK, tower.DEF, useCrit)
-	tower.HP -= damage
-	// Gửi kết quả cho người chơi đang hành động
-	network.SendPDU(conn, "result", fmt.Sprintf("💥 %s dealt %d damage to %s", troop.Name, damage, tower.Type))
-
-	// Gửi cập nhật HP cho cả hai client
-	// gs.Broadcast(fmt.Sprintf("📉 %s HP is now %d", tower.Type, tower.HP))
-	attacker.Mana -= troop.Mana
-	attacker.Troops = append(attacker.Troops[:troopIndex], attacker.Troops[troopIndex+1:]...)
-
-	if tower.HP <= 0 {
-		gs.Broadcast(fmt.Sprintf("🏰 %s destroyed!", tower.Type))
-		if tower.Type == "King Tower" {
-			gs.GameOver = true
-			gs.Broadcast(fmt.Sprintf("🎉 %s wins by destroying the King Tower!", attacker.Username))
-			AddExp(attacker, 30)
-			AddExp(defender, 10)
-			gs.signalGameOver()
+
+	me := gs.index(attacker)
+	troopIndex := -1
+	for i, t := range attacker.Troops {
+		if strings.EqualFold(t.Name, req.Troop) {
+			troopIndex = i
+			break
 		}
 	}
+	if troopIndex >= 0 && engine.IsCast(attacker.Troops[troopIndex]) {
+		gs.act(attacker, conn, pdu, engine.Heal{Player: me, Card: troopIndex})
+		return
+	}
+	gs.act(attacker, conn, pdu, attackAction(me, troopIndex, req.Tower, req.UseCrit))
 }
 
-func (gs *GameSession) endGameByTime() {
-	p1Destroyed := countDestroyedTowers(gs.Player2)
-	p2Destroyed := countDestroyedTowers(gs.Player1)
-
-	gs.Broadcast("⏰ Time is up! Calculating results...")
-
-	switch {
-	case p1Destroyed > p2Destroyed:
-		gs.Broadcast(fmt.Sprintf("🎉 %s wins (%d towers destroyed)!", gs.Player1.Username, p1Destroyed))
-		AddExp(gs.Player1, 20)
-		AddExp(gs.Player2, 5)
-	case p2Destroyed > p1Destroyed:
-		gs.Broadcast(fmt.Sprintf("🎉 %s wins (%d towers destroyed)!", gs.Player2.Username, p2Destroyed))
-		AddExp(gs.Player2, 20)
-		AddExp(gs.Player1, 5)
-	default:
-		gs.Broadcast("🤝 It's a draw!")
-		AddExp(gs.Player1, 10)
-		AddExp(gs.Player2, 10)
+// attackAction là action cho card troopIndex đánh tower target.
+func attackAction(player, troopIndex, target int, useCrit bool) engine.Action {
+	if useCrit {
+		return engine.Crit{Player: player, Card: troopIndex, Tower: target}
 	}
+	return engine.Deploy{Player: player, Card: troopIndex, Tower: target}
+}
+
+func showStatus(conn *network.Conn, player *models.Player) {
+	snapshot := statusSnapshot(player)
+	jsonData, _ := json.MarshalIndent(snapshot, "", " ")
+	conn.Send(network.MsgStatusSnapshot, string(jsonData), snapshot)
 }
 
-func countDestroyedTowers(player *models.Player) int {
-	count := 0
+// statusSnapshot builds the public view of a player; it never includes the password.
+func statusSnapshot(player *models.Player) network.StatusSnapshot {
+	snapshot := network.StatusSnapshot{
+		Username:  player.Username,
+		Level:     player.Level,
+		EXP:       player.EXP,
+		Rating:    utils.PlayerRating(player),
+		Mana:      player.Mana,
+		CritsLeft: player.CritsLeft,
+		Towers:    []network.TowerState{},
+		Troops:    []network.TroopCard{},
+	}
 	for _, t := range player.Towers {
-		if t.HP <= 0 {
-			count++
-		}
+		snapshot.Towers = append(snapshot.Towers, network.TowerState{Type: t.Type, HP: t.HP, ATK: t.ATK, DEF: t.DEF})
+	}
+	for _, t := range player.Troops {
+		snapshot.Troops = append(snapshot.Troops, troopCard(t))
 	}
-	return count
+	if next, ok := nextCard(player); ok {
+		card := troopCard(next)
+		snapshot.Next = &card
+	}
+	return snapshot
 }
 
-func showStatus(conn net.Conn, player *models.Player) {
-	status := map[string]interface{}{
-		"username":  player.Username,
-		"level":     player.Level,
-		"exp":       player.EXP,
-		"mana":      player.Mana,
-		"towers":    player.Towers,
-		"troops":    player.Troops,
-		"critsLeft": player.CritsLeft,
-	}
-	jsonData, _ := json.MarshalIndent(status, "", " ")
-	network.SendPDU(conn, "status", string(jsonData))
+func troopCard(t models.Troop) network.TroopCard {
+	return network.TroopCard{Name: t.Name, ATK: t.ATK, DEF: t.DEF, Mana: t.Mana, Special: t.Special}
 }
 
 func parseIndex(input string) int {
@@ -403,17 +469,3 @@ func parseIndex(input string) int {
 	fmt.Sscanf(input, "%d", &idx)
 	return idx
 }
-
-func getRandomTroops(all []models.Troop, count int) []models.Troop {
-	rand.Seed(time.Now().UnixNano())
-	var selected []models.Troop
-	used := make(map[int]bool)
-	for len(selected) < count && len(used) < len(all) {
-		i := rand.Intn(len(all))
-		if !used[i] {
-			selected = append(selected, all[i])
-			used[i] = true
-		}
-	}
-	return selected
-}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/storage"
+)
+
+func TestSaveProgressKeepsLobbyChanges(t *testing.T) {
+	store, err := storage.NewSQLStore(filepath.Join(t.TempDir(), "clash.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	towers := []models.Tower{{Type: "King Tower", HP: 2000, EXP: 10}}
+	var inMatch [2]*models.Player
+	for i, name := range []string{"alice", "bob"} {
+		p := &models.Player{Username: name, Level: 1, Gold: 100, Towers: towers, Deck: []string{"Pawn"}}
+		if err := store.Put(p); err != nil {
+			t.Fatal(err)
+		}
+		inMatch[i], _ = store.Get(name)
+	}
+
+	// Trong lúc đấu alice đổi deck và nâng cấp từ một phiên khác
+	if err := store.Update("alice", func(p *models.Player) error {
+		p.Deck = []string{"Knight"}
+		p.Gold = 40
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := inMatch[0]
+	alice.Gold, alice.EXP, alice.Rating = 130, 30, 1016
+	alice.Towers[0].HP = 500
+	alice.Mana = 3
+	alice.Troops = []models.Troop{{Name: "Pawn"}}
+
+	gs := &GameSession{Player1: inMatch[0], Player2: inMatch[1], Store: store, Rules: models.DefaultRuleset()}
+	gs.saveProgress()
+
+	stored, _ := store.Get("alice")
+	if stored.Gold != 130 || stored.EXP != 30 || stored.Rating != 1016 {
+		t.Errorf("progress = gold %d exp %d rating %d, want 130 30 1016", stored.Gold, stored.EXP, stored.Rating)
+	}
+	if stored.TowerLevels["King Tower"].EXP != 10 {
+		t.Errorf("tower EXP = %d, want 10", stored.TowerLevels["King Tower"].EXP)
+	}
+	if !slices.Equal(stored.Deck, []string{"Knight"}) {
+		t.Errorf("deck = %v, want the deck saved during the match", stored.Deck)
+	}
+	if stored.Towers[0].HP != 2000 || stored.Mana != 0 || len(stored.Troops) != 0 {
+		t.Errorf("in-match state was saved: tower HP %d, mana %d, hand %v", stored.Towers[0].HP, stored.Mana, stored.Troops)
+	}
+}
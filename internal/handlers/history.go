@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+)
+
+const (
+	// HistoryHelp là hướng dẫn lệnh history cho client văn bản.
+	HistoryHelp = "📜 Type 'history' to see the results of your recent matches."
+	// maxHistoryList là số trận tối đa trong lịch sử gửi cho client.
+	maxHistoryList = 10
+)
+
+// HandleHistoryCommand trả lời lệnh 'history' (hoặc PDU match_history) bằng
+// kết quả các trận gần đây của player; trả về false nếu PDU không phải lệnh này.
+func HandleHistoryCommand(conn *network.Conn, pdu network.PDU, player *models.Player, store storage.PlayerStore) bool {
+	if pdu.Type != network.MsgMatchHistory && !strings.EqualFold(strings.TrimSpace(pdu.Payload), "history") {
+		return false
+	}
+	historian, ok := store.(storage.MatchHistorian)
+	if !ok {
+		conn.Reply(pdu, "error", "❌ Match history is not available on this server.", nil)
+		return true
+	}
+	matches, err := historian.MatchHistory(player.Username, maxHistoryList)
+	if err != nil {
+		fmt.Printf("❌ Failed to load match history for %s: %v\n", player.Username, err)
+		conn.Reply(pdu, "error", "❌ Failed to load match history.", nil)
+		return true
+	}
+
+	history := network.MatchHistory{Matches: []network.MatchResult{}}
+	text := "📜 You have not played any matches yet."
+	if len(matches) > 0 {
+		text = "📜 Your recent matches:"
+	}
+	for _, m := range matches {
+		r := matchResult(m, player.Username)
+		history.Matches = append(history.Matches, r)
+		text += fmt.Sprintf("\n#%d vs %s (%s): %s", r.ID, r.Opponent, r.Mode, r.Result)
+		if r.Reason != "" {
+			text += " by " + r.Reason
+		}
+	}
+	conn.Reply(pdu, network.MsgMatchHistory, text, history)
+	return true
+}
+
+// matchResult chuyển một dòng lịch sử đấu thành kết quả theo góc nhìn của username.
+func matchResult(m storage.MatchSummary, username string) network.MatchResult {
+	r := network.MatchResult{ID: m.ID, Opponent: m.Player2, Mode: m.Mode, Reason: m.Reason, StartedAt: m.StartedAt}
+	if m.Player2 == username {
+		r.Opponent = m.Player1
+	}
+	switch {
+	case m.Reason == "":
+		r.Result = "unfinished"
+	case m.Winner == "":
+		r.Result = "draw"
+	case m.Winner == username:
+		r.Result = "win"
+	default:
+		r.Result = "loss"
+	}
+	return r
+}
+
+// startRecording mở một bản ghi trận đấu nếu store có lưu lịch sử.
+func (gs *GameSession) startRecording() {
+	recorder, ok := gs.Store.(storage.MatchRecorder)
+	if !ok {
+		return
+	}
+	id, err := recorder.StartMatch(gs.Player1.Username, gs.Player2.Username, gs.Mode.String(), gs.Seed)
+	if err != nil {
+		fmt.Printf("❌ Failed to record match start: %v\n", err)
+		return
+	}
+	gs.matchID = id
+}
+
+// recordEvent ghi nhớ một sự kiện của trận đấu, data được lưu dưới dạng JSON.
+// Sự kiện chỉ được ghi vào store khi trận kết thúc (flushEvents) để vòng
+// lặp real-time không phải chờ database ở mỗi tick.
+func (gs *GameSession) recordEvent(kind, player string, data interface{}) {
+	if gs.matchID == 0 {
+		return
+	}
+	detail, _ := json.Marshal(data)
+	gs.events = append(gs.events, storage.MatchEvent{At: time.Now(), Kind: kind, Player: player, Detail: string(detail)})
+}
+
+// flushEvents ghi các sự kiện đã ghi nhớ vào lịch sử đấu.
+func (gs *GameSession) flushEvents() {
+	recorder, ok := gs.Store.(storage.MatchRecorder)
+	if !ok || len(gs.events) == 0 {
+		return
+	}
+	if err := recorder.RecordEvents(gs.matchID, gs.events); err != nil {
+		fmt.Printf("❌ Failed to record match events: %v\n", err)
+	}
+	gs.events = nil
+}
+
+// announceGameOver cập nhật rating theo kết quả (trừ trận luyện tập), thông
+// báo cho cả hai người chơi và lưu vào lịch sử.
+func (gs *GameSession) announceGameOver(text string, result network.GameOver) {
+	if !gs.practice() {
+		result.Ratings = gs.updateRatings(result.Winner)
+		text += fmt.Sprintf("\n📈 Ratings: %s %d, %s %d", gs.Player1.Username, gs.Player1.Rating, gs.Player2.Username, gs.Player2.Rating)
+	}
+	gs.BroadcastEvent(network.MsgGameOver, text, result)
+
+	recorder, ok := gs.Store.(storage.MatchRecorder)
+	if !ok || gs.matchID == 0 {
+		return
+	}
+	if err := recorder.FinishMatch(gs.matchID, result.Winner, result.Reason); err != nil {
+		fmt.Printf("❌ Failed to record match result: %v\n", err)
+	}
+}
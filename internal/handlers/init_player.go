@@ -17,6 +17,7 @@ func InitNewPlayer(player *models.Player) error {
 	player.Mana = 10
 	player.Level = 1
 	player.EXP = 0
+	player.Rating = utils.InitialRating
 
 	return nil
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/replay"
+)
+
+// playerInput là một PDU (hoặc lỗi đọc) do goroutine đọc của một kết nối gửi lên.
+type playerInput struct {
+	player *models.Player
+	conn   *network.Conn
+	pdu    network.PDU
+	err    error
+}
+
+// run is the session's game loop. It is the only goroutine that reads or
+// changes match state: player commands, clock ticks, mana or simulation
+// ticks and reconnects are all serialized through it.
+func (gs *GameSession) run() {
+	gs.startReader(gs.Player1, gs.Conn1)
+	gs.startReader(gs.Player2, gs.Conn2)
+
+	clock := time.NewTicker(time.Second)
+	defer clock.Stop()
+
+	// Chế độ real-time hồi mana trong tick mô phỏng; chế độ theo lượt dùng ticker riêng
+	var mana, sim <-chan time.Time
+	if gs.Mode == models.ModeRealtime {
+		ticker := time.NewTicker(SimTickDuration)
+		defer ticker.Stop()
+		sim = ticker.C
+		gs.startRealtime()
+	} else {
+		ticker := time.NewTicker(TickDuration)
+		defer ticker.Stop()
+		mana = ticker.C
+		gs.startTurn()
+	}
+
+	for !gs.GameOver {
+		select {
+		case in := <-gs.inputs:
+			gs.handleInput(in)
+		case req := <-gs.reconnects:
+			gs.handleReconnect(req)
+		case sp := <-gs.spectates:
+			gs.addSpectator(sp)
+		case in := <-gs.spectatorInputs:
+			gs.handleSpectatorInput(in)
+		case sp := <-gs.spectatorLeaves:
+			gs.removeSpectator(sp, "")
+		case now := <-clock.C:
+			gs.checkClock(now)
+		case <-mana:
+			if !gs.isPaused() {
+				regenerateMana(gs.Rules, gs.Player1, gs.Player2)
+			}
+		case <-sim:
+			if !gs.isPaused() {
+				gs.simulate()
+			}
+		}
+	}
+
+	gs.stopReaders()
+	unregisterSession(gs)
+	gs.endSpectating()
+	gs.closeReplay()
+	gs.flushEvents()
+	gs.saveProgress()
+	// Wait briefly to ensure all PDUs are sent
+	time.Sleep(500 * time.Millisecond)
+	gs.askRematch()
+}
+
+// startReader đọc PDU từ conn và chuyển vào vòng lặp cho tới khi lỗi hoặc trận kết thúc.
+func (gs *GameSession) startReader(p *models.Player, conn *network.Conn) {
+	gs.readers.Add(1)
+	go func() {
+		defer gs.readers.Done()
+		for {
+			pdu, err := conn.ReadPDU()
+			if errors.Is(err, network.ErrReadCancelled) {
+				return
+			}
+			select {
+			case gs.inputs <- playerInput{player: p, conn: conn, pdu: pdu, err: err}:
+			case <-gs.done:
+				// Trả PDU lại cho askRematch thay vì bỏ mất
+				if err == nil {
+					conn.Unread(pdu)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// stopReaders dừng mọi goroutine đọc để askRematch có thể đọc trực tiếp từ kết nối.
+func (gs *GameSession) stopReaders() {
+	close(gs.done)
+	gs.Conn1.CancelRead()
+	gs.Conn2.CancelRead()
+	gs.readers.Wait()
+	gs.Conn1.ResetCancel()
+	gs.Conn2.ResetCancel()
+}
+
+func (gs *GameSession) handleInput(in playerInput) {
+	// PDU từ kết nối cũ đã được thay thế khi người chơi kết nối lại
+	if in.conn != gs.connFor(in.player) {
+		return
+	}
+	if in.err != nil {
+		gs.handleDisconnect(in.player)
+		return
+	}
+	gs.recordReplay(replay.KindAction, in.pdu.Type, in.player.Username, in.pdu.Payload, in.pdu.Body())
+	if gs.isPaused() {
+		in.conn.SendPDU("error", "⏸️ The match is paused until your opponent reconnects.")
+		return
+	}
+	if gs.Mode == models.ModeRealtime {
+		gs.handleRealtimeInput(in.player, in.conn, in.pdu)
+		return
+	}
+	if in.player != gs.TurnOwner {
+		// Đầu hàng được cả khi không phải lượt của mình
+		if choice := strings.TrimSpace(in.pdu.Payload); choice == "4" || strings.EqualFold(choice, "surrender") {
+			gs.act(in.player, in.conn, in.pdu, engine.Surrender{Player: gs.index(in.player)})
+			return
+		}
+		in.conn.SendPDU("error", "⏳ Please wait for your turn. Type '4' to surrender.")
+		return
+	}
+	gs.handleTurnInput(in.player, in.conn, in.pdu)
+}
+
+// checkClock kết thúc trận khi hết giờ hoặc khi người chơi mất kết nối quá lâu.
+func (gs *GameSession) checkClock(now time.Time) {
+	for p, deadline := range gs.disconnected {
+		if now.After(deadline) {
+			gs.forfeit(p)
+			return
+		}
+	}
+	if gs.IsTimedGame && gs.GameTimer.IsTimeUp() {
+		next, events := engine.EndByTime(gs.engineState())
+		gs.applyState(next)
+		gs.render(events, nil, network.PDU{})
+	}
+}
+
+func (gs *GameSession) isPaused() bool {
+	return len(gs.disconnected) > 0
+}
+
+func (gs *GameSession) forfeit(p *models.Player) {
+	opponent := gs.opponentOf(p)
+	gs.GameOver = true
+	gs.announceGameOver(
+		fmt.Sprintf("🏳️ %s did not reconnect in time. %s wins!", p.Username, opponent.Username),
+		network.GameOver{Winner: opponent.Username, Reason: "disconnect"})
+	gs.reward(opponent, gs.Rules.Rewards.ForfeitWin)
+	gs.signalGameOver()
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+func TestReaderKeepsPDUAfterMatchEnds(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	server, client := network.NewConn(a), network.NewConn(b)
+
+	gs := &GameSession{inputs: make(chan playerInput), done: make(chan struct{})}
+	close(gs.done)
+	go client.Send("rematch", "yes", nil)
+	gs.startReader(&models.Player{Username: "alice"}, server)
+	gs.readers.Wait()
+
+	server.ReadTimeout = time.Second
+	pdu, err := server.ReadPDU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdu.Type != "rematch" {
+		t.Fatalf("next PDU = %q, want the rematch answer read by the stopped reader", pdu.Type)
+	}
+}
@@ -1,33 +1,22 @@
 package handlers
 
 import (
-	"sync"
 	"time"
 
 	"net-centric-clash-royale/internal/models"
 )
 
-const (
-	ManaRegenRate = 1           // mana regen mỗi giây
-	MaxMana       = 10          // giới hạn tối đa
-	TickDuration  = time.Second // 1 giây
-)
+const TickDuration = time.Second // 1 giây
 
-// StartManaRegeneration sẽ chạy liên tục và hồi mana cho người chơi
-func StartManaRegeneration(players []*models.Player, mutex *sync.Mutex) {
-	go func() {
-		for {
-			time.Sleep(TickDuration)
-			mutex.Lock()
-			for _, p := range players {
-				if p.Mana < MaxMana {
-					p.Mana += ManaRegenRate
-					if p.Mana > MaxMana {
-						p.Mana = MaxMana
-					}
-				}
+// regenerateMana hồi mana cho người chơi theo luật của trận, được gọi mỗi
+// TickDuration từ vòng lặp trận đấu.
+func regenerateMana(rules models.Ruleset, players ...*models.Player) {
+	for _, p := range players {
+		if p.Mana < rules.MaxMana {
+			p.Mana += rules.ManaRegenRate
+			if p.Mana > rules.MaxMana {
+				p.Mana = rules.MaxMana
 			}
-			mutex.Unlock()
 		}
-	}()
+	}
 }
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+// ModeMenu is the game mode prompt shown for rematches, with the match
+// length taken from rules.
+func ModeMenu(rules models.Ruleset) string {
+	return modeChoices(rules) + "\nEnter 1, 2 or 3:"
+}
+
+// LobbyMenu là menu chọn chế độ ở sảnh chờ, thêm lựa chọn đấu với bot.
+func LobbyMenu(rules models.Ruleset) string {
+	return modeChoices(rules) + "\n4. Play vs Bot (practice)\nEnter 1, 2, 3 or 4:"
+}
+
+func modeChoices(rules models.Ruleset) string {
+	return fmt.Sprintf("Choose game mode:\n1. %s\n2. %s\n3. %s",
+		models.ModeTimed.Title(rules), models.ModeUntimed.Title(rules), models.ModeRealtime.Title(rules))
+}
+
+// ModeSupported reports whether the client on conn negotiated the feature
+// mode needs.
+func ModeSupported(conn *network.Conn, mode models.GameMode) bool {
+	switch mode {
+	case models.ModeTimed:
+		return conn.Supports(network.FeatureTimed)
+	case models.ModeRealtime:
+		return conn.Supports(network.FeatureRealtime)
+	}
+	return true
+}
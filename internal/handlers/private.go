@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+const (
+	// PrivateMatchTTL là thời gian chủ phòng chờ người chơi thứ hai nhập mã mời.
+	PrivateMatchTTL = 5 * time.Minute
+	// inviteCodeLength là số ký tự của mã mời.
+	inviteCodeLength = 6
+	// inviteAlphabet bỏ các ký tự dễ nhầm như 0/O và 1/I.
+	inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// PrivateHelp là hướng dẫn lệnh private match cho client văn bản.
+const PrivateHelp = "🔒 Type 'private [timed|untimed|realtime] [duration=<sec>] [mana=<n>] [cards=<card>,<card>,...]' to create a private match, or 'join <code>' to join one."
+
+// PrivateMatch là một trận riêng đang chờ người chơi thứ hai.
+type PrivateMatch struct {
+	Code  string
+	Mode  models.GameMode
+	Rules models.Ruleset
+	Host  *models.Player
+	Conn  *network.Conn
+	// Joined được đóng khi có người nhập mã. Chủ phòng ngừng đọc Conn rồi
+	// đóng Released; từ đó trận đấu sở hữu Conn của chủ phòng.
+	Joined   chan struct{}
+	Released chan struct{}
+}
+
+var (
+	privateMatches   = make(map[string]*PrivateMatch)
+	privateMatchesMu sync.Mutex
+)
+
+// CreatePrivateMatch đăng ký một trận riêng của host và trả về mã mời mới.
+func CreatePrivateMatch(host *models.Player, conn *network.Conn, mode models.GameMode, rules models.Ruleset) (*PrivateMatch, error) {
+	privateMatchesMu.Lock()
+	defer privateMatchesMu.Unlock()
+	for {
+		code, err := newInviteCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, taken := privateMatches[code]; taken {
+			continue
+		}
+		m := &PrivateMatch{
+			Code: code, Mode: mode, Rules: rules, Host: host, Conn: conn,
+			Joined: make(chan struct{}), Released: make(chan struct{}),
+		}
+		privateMatches[code] = m
+		return m, nil
+	}
+}
+
+// Lỗi khi vào trận riêng.
+var (
+	ErrInviteNotFound  = errors.New("invalid or expired invite code")
+	ErrModeUnsupported = errors.New("your client does not support this match's game mode")
+)
+
+// JoinPrivateMatch lấy trận riêng có mã code ra khỏi danh sách chờ cho
+// username; chủ phòng không tự vào trận của mình được. Nếu supports(mode) là
+// false thì mã mời vẫn được giữ cho người khác. Người gọi phải đóng Joined
+// và chờ Released trước khi bắt đầu trận đấu.
+func JoinPrivateMatch(code, username string, supports func(models.GameMode) bool) (*PrivateMatch, error) {
+	privateMatchesMu.Lock()
+	defer privateMatchesMu.Unlock()
+	m, ok := privateMatches[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok || m.Host.Username == username {
+		return nil, ErrInviteNotFound
+	}
+	if !supports(m.Mode) {
+		return nil, ErrModeUnsupported
+	}
+	delete(privateMatches, m.Code)
+	return m, nil
+}
+
+// ParseJoinRequest đọc mã mời từ PDU có kiểu hoặc dòng "join <code>". ok là
+// false nếu PDU không phải lệnh vào trận riêng.
+func ParseJoinRequest(pdu network.PDU) (code string, ok bool, err error) {
+	if pdu.Type == network.MsgJoinPrivate {
+		var req network.JoinPrivate
+		if err := pdu.DecodeData(&req); err != nil {
+			return "", true, err
+		}
+		return req.Code, true, nil
+	}
+	fields := strings.Fields(pdu.Payload)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "join") {
+		return "", false, nil
+	}
+	return fields[1], true, nil
+}
+
+// CancelPrivateMatch huỷ mã mời; trả về false nếu đã có người vào trận.
+func CancelPrivateMatch(m *PrivateMatch) bool {
+	privateMatchesMu.Lock()
+	defer privateMatchesMu.Unlock()
+	if privateMatches[m.Code] != m {
+		return false
+	}
+	delete(privateMatches, m.Code)
+	return true
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// ParsePrivateRequest đọc lệnh tạo trận riêng (PDU có kiểu hoặc dòng
+// "private ..."), áp các tuỳ chọn lên base và kiểm tra luật thu được.
+// ok là false nếu PDU không phải lệnh tạo trận riêng.
+func ParsePrivateRequest(pdu network.PDU, base models.Ruleset) (mode models.GameMode, rules models.Ruleset, ok bool, err error) {
+	var req network.CreatePrivate
+	switch {
+	case pdu.Type == network.MsgCreatePrivate:
+		if pdu.HasData() {
+			if err := pdu.DecodeData(&req); err != nil {
+				return models.ModeUntimed, base, true, err
+			}
+		}
+	default:
+		fields := strings.Fields(pdu.Payload)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "private") {
+			return models.ModeUntimed, base, false, nil
+		}
+		for _, f := range fields[1:] {
+			key, value, _ := strings.Cut(f, "=")
+			switch strings.ToLower(key) {
+			case "timed", "untimed", "realtime":
+				req.Mode = strings.ToLower(key)
+			case "duration":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return models.ModeUntimed, base, true, fmt.Errorf("invalid duration %q", value)
+				}
+				req.DurationSec = n
+			case "mana":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return models.ModeUntimed, base, true, fmt.Errorf("invalid mana %q", value)
+				}
+				req.StartMana = &n
+			case "cards":
+				req.Cards = strings.Split(value, ",")
+			default:
+				return models.ModeUntimed, base, true, fmt.Errorf("unknown option %q", f)
+			}
+		}
+	}
+
+	mode, ok = models.ParseModeName(req.Mode)
+	if !ok && req.Mode != "" {
+		return models.ModeUntimed, base, true, fmt.Errorf("unknown mode %q", req.Mode)
+	}
+	rules = base
+	if req.DurationSec != 0 {
+		rules.GameDurationSec = req.DurationSec
+	}
+	if req.StartMana != nil {
+		rules.StartMana = *req.StartMana
+	}
+	if len(req.Cards) > 0 {
+		rules.AllowedCards = nil
+		for _, name := range req.Cards {
+			t, found := findTroop(rules.Troops, name)
+			if !found {
+				return mode, rules, true, fmt.Errorf("unknown card %q", name)
+			}
+			rules.AllowedCards = append(rules.AllowedCards, t.Name)
+		}
+	}
+	if err := rules.Validate(); err != nil {
+		return mode, rules, true, err
+	}
+	return mode, rules, true, nil
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "net-centric-clash-royale/internal/utils"
+
+// updateRatings cập nhật Elo của hai người chơi theo người thắng (rỗng nếu
+// hoà) và trả về rating mới theo username.
+func (gs *GameSession) updateRatings(winner string) map[string]int {
+	score := 0.5
+	switch winner {
+	case gs.Player1.Username:
+		score = 1
+	case gs.Player2.Username:
+		score = 0
+	}
+	gs.Player1.Rating, gs.Player2.Rating = utils.UpdateElo(utils.PlayerRating(gs.Player1), utils.PlayerRating(gs.Player2), score)
+	return map[string]int{
+		gs.Player1.Username: gs.Player1.Rating,
+		gs.Player2.Username: gs.Player2.Rating,
+	}
+}
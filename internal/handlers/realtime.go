@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/arena"
+	"net-centric-clash-royale/internal/engine"
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/utils"
+)
+
+const (
+	TickRate         = 10                     // số tick mô phỏng mỗi giây
+	SimTickDuration  = time.Second / TickRate // 100ms
+	TroopAttackTicks = TickRate               // mỗi troop đánh một lần mỗi giây
+)
+
+// unit là một troop đã được thả xuống sân trong trận real-time.
+type unit struct {
+	id       int
+	owner    *models.Player
+	side     arena.Side
+	troop    models.Troop
+	lane     arena.Lane
+	pos      float64
+	hp       int
+	cooldown int  // số tick còn lại trước đòn đánh tiếp theo
+	fighting bool // đang đứng đánh troop hoặc tower
+	fx       engine.Effects
+	traveled float64 // quãng đường đã đi kể từ đòn đánh trước
+	attacked bool    // đã đánh ít nhất một đòn
+}
+
+// newUnit thả troop của p xuống lane tại pos.
+func (gs *GameSession) newUnit(p *models.Player, troop models.Troop, lane arena.Lane, pos float64) *unit {
+	gs.nextUnitID++
+	u := &unit{id: gs.nextUnitID, owner: p, side: gs.sideOf(p), troop: troop, lane: lane, pos: pos, hp: troop.HP, fx: engine.SpawnEffects(troop)}
+	gs.units = append(gs.units, u)
+	return u
+}
+
+func (u *unit) speed() float64 {
+	if u.troop.Speed > 0 {
+		return u.troop.Speed
+	}
+	return arena.DefaultSpeed
+}
+
+func (u *unit) state() network.UnitState {
+	state := "walking"
+	if u.fighting {
+		state = "attacking"
+	}
+	return network.UnitState{ID: u.id, Owner: u.owner.Username, Troop: u.troop.Name, Lane: u.lane.String(), Pos: u.pos, State: state, HP: u.hp}
+}
+
+// tickDelta gom những thay đổi trong một tick để gửi cho client.
+type tickDelta struct {
+	units   []network.UnitState
+	removed []int
+	towers  []network.TowerUpdate
+	text    []string
+}
+
+// startRealtime gửi hướng dẫn và bài trên tay cho cả hai người chơi.
+func (gs *GameSession) startRealtime() {
+	gs.towerFx[arena.Home] = make([]engine.Effects, len(gs.Player1.Towers))
+	gs.towerFx[arena.Away] = make([]engine.Effects, len(gs.Player2.Towers))
+	gs.Broadcast("⚔️ Real-time battle! Deploy troops any time: enter <troop#> [L|R] to send a troop down the left or right lane, or 'status'.")
+	gs.sendHand(gs.Player1)
+	gs.sendHand(gs.Player2)
+}
+
+func (gs *GameSession) sendHand(p *models.Player) {
+	gs.connFor(p).SendPDU("select", fmt.Sprintf("🃏 Your hand (Mana: %d):\n", p.Mana)+handText(p))
+}
+
+// handleRealtimeInput xử lý lệnh deploy; cả hai người chơi gửi lệnh bất cứ lúc nào.
+func (gs *GameSession) handleRealtimeInput(p *models.Player, conn *network.Conn, pdu network.PDU) {
+	if pdu.Type == network.MsgDeploy {
+		var req network.Deploy
+		if err := pdu.DecodeData(&req); err != nil {
+			conn.Reply(pdu, "error", "❌ Invalid deploy request.", nil)
+			return
+		}
+		troopIndex := -1
+		for i, t := range p.Troops {
+			if strings.EqualFold(t.Name, req.Troop) {
+				troopIndex = i
+				break
+			}
+		}
+		lane, ok := arena.ParseLane(req.Lane)
+		if !ok && req.Lane != "" {
+			conn.Reply(pdu, "error", "❌ Invalid lane.", nil)
+			return
+		}
+		gs.deploy(p, conn, pdu, troopIndex, lane)
+		return
+	}
+
+	fields := strings.Fields(pdu.Payload)
+	if len(fields) == 0 {
+		conn.SendPDU("error", "❗ Enter <troop#> [L|R].")
+		return
+	}
+	if strings.EqualFold(fields[0], "status") {
+		showStatus(conn, p)
+		return
+	}
+	lane := arena.Left
+	if len(fields) > 1 {
+		var ok bool
+		if lane, ok = arena.ParseLane(fields[1]); !ok {
+			conn.SendPDU("error", "❌ Invalid lane. Use L or R.")
+			return
+		}
+	}
+	gs.deploy(p, conn, pdu, parseIndex(fields[0])-1, lane)
+}
+
+// deploy dùng card qua engine (trừ mana, rút bài thay thế) rồi thả troop
+// xuống lane trước Guard Tower của mình; troop dùng ngay không ra trận.
+func (gs *GameSession) deploy(p *models.Player, conn *network.Conn, req network.PDU, troopIndex int, lane arena.Lane) {
+	play := engine.Play
+	cast := troopIndex >= 0 && troopIndex < len(p.Troops) && engine.IsCast(p.Troops[troopIndex])
+	if cast {
+		play = engine.Cast
+	}
+	next, events, err := play(gs.engineState(), gs.index(p), troopIndex)
+	if err != nil {
+		conn.Reply(req, "error", actionError(err), nil)
+		return
+	}
+	troop := p.Troops[troopIndex]
+	gs.applyState(next)
+	gs.render(events, conn, req)
+	if !cast {
+		u := gs.newUnit(p, troop, lane, arena.SpawnPos(gs.sideOf(p)))
+		gs.BroadcastEvent(network.MsgStateDelta,
+			fmt.Sprintf("🚀 %s deployed %s in the %s lane", p.Username, troop.Name, lane),
+			network.StateDelta{Tick: gs.tick, Units: []network.UnitState{u.state()}})
+		gs.recordEvent(network.MsgDeploy, p.Username, network.Deploy{Troop: troop.Name, Lane: lane.String()})
+	}
+	gs.sendHand(p)
+}
+
+// simulate chạy một tick: troop di chuyển và đánh nhau, tower bắn và hồi mana.
+func (gs *GameSession) simulate() {
+	gs.tick++
+	var delta tickDelta
+	second := gs.tick%TickRate == 0
+	if second {
+		regenerateMana(gs.Rules, gs.Player1, gs.Player2)
+		gs.tickEffects(&delta)
+	}
+
+	for _, u := range gs.units {
+		if gs.GameOver {
+			break
+		}
+		if u.hp > 0 {
+			gs.stepUnit(u, &delta)
+		}
+	}
+	if second && !gs.GameOver {
+		gs.towersFire(gs.Player1, &delta)
+		gs.towersFire(gs.Player2, &delta)
+	}
+	gs.removeDeadUnits(&delta)
+	// Mỗi giây gửi vị trí của mọi troop, giữa các giây chỉ gửi thay đổi
+	if second {
+		delta.units = gs.unitStates()
+	}
+
+	if len(delta.units) == 0 && len(delta.removed) == 0 && len(delta.towers) == 0 && !second {
+		return
+	}
+	text := strings.Join(delta.text, "\n")
+	state := network.StateDelta{
+		Tick:    gs.tick,
+		Units:   delta.units,
+		Removed: delta.removed,
+		Towers:  delta.towers,
+	}
+	for _, p := range []*models.Player{gs.Player1, gs.Player2} {
+		state.Mana = p.Mana
+		gs.connFor(p).Send(network.MsgStateDelta, text, state)
+	}
+	// Người xem không có mana riêng
+	state.Mana = 0
+	gs.spectate(network.MsgStateDelta, text, state)
+}
+
+// stepUnit cho troop đi dọc lane tới vật cản gần nhất phía trước (troop hoặc
+// tower của đối thủ) rồi đánh nó.
+func (gs *GameSession) stepUnit(u *unit, delta *tickDelta) {
+	if u.cooldown > 0 {
+		u.cooldown--
+	}
+	if u.fx.Stun > 0 {
+		return
+	}
+	defender := gs.opponentOf(u.owner)
+	var foe *unit
+	if !engine.TowersOnly(u.troop) {
+		foe = gs.foeAhead(u)
+	}
+	building, hasBuilding := gs.arena.FirstBuilding(u.side, u.lane, engine.TowerAlive(defender.Towers))
+	if foe == nil && !hasBuilding {
+		return
+	}
+
+	// Chọn vật cản gặp trước trên đường đi
+	stop := 0.0
+	if hasBuilding {
+		stop = arena.FightPos(u.side, building)
+	}
+	if foe != nil {
+		foeStop := foe.pos - u.side.Forward()*arena.MeleeRange
+		if !hasBuilding || u.side.Forward()*(foeStop-stop) < 0 {
+			stop, hasBuilding = foeStop, false
+		}
+	}
+
+	fighting := u.side.Forward()*(stop-u.pos) <= 1e-9
+	if fighting != u.fighting {
+		u.fighting = fighting
+		delta.units = append(delta.units, u.state())
+	}
+	if !fighting {
+		next := arena.Advance(u.side, u.pos, u.speed()/TickRate, stop)
+		u.traveled += math.Abs(next - u.pos)
+		u.pos = next
+		return
+	}
+	if u.cooldown > 0 {
+		return
+	}
+	u.cooldown = TroopAttackTicks
+	if hasBuilding {
+		gs.unitAttack(u, building.Index, delta)
+	} else {
+		gs.unitFight(u, foe, delta)
+	}
+	u.traveled, u.attacked = 0, true
+}
+
+// foeAhead trả về troop đối phương gần nhất trên cùng lane, phía trước u.
+func (gs *GameSession) foeAhead(u *unit) *unit {
+	var nearest *unit
+	for _, f := range gs.units {
+		if f.side == u.side || f.lane != u.lane || f.hp <= 0 {
+			continue
+		}
+		ahead := u.side.Forward() * (f.pos - u.pos)
+		if ahead < 0 {
+			continue
+		}
+		if nearest == nil || ahead < u.side.Forward()*(nearest.pos-u.pos) {
+			nearest = f
+		}
+	}
+	return nearest
+}
+
+// unitFight cho troop u đánh troop foe của đối thủ.
+func (gs *GameSession) unitFight(u, foe *unit, delta *tickDelta) {
+	h := engine.NewHit(u.troop, utils.CalculateDamage(u.troop.ATK, foe.troop.DEF, false, gs.Rules.CritMultiplier), !u.attacked, u.traveled, &foe.fx,
+		func(damage int, radius float64) {
+			for _, f := range gs.units {
+				if f != foe && f.side == foe.side && f.lane == foe.lane && f.hp > 0 && math.Abs(f.pos-foe.pos) <= radius {
+					gs.damageUnit(f, damage, "💥 "+u.troop.Name+" splash", delta)
+				}
+			}
+		})
+	gs.damageUnit(foe, h.Damage, fmt.Sprintf("⚔️ %s's %s%s", u.owner.Username, u.troop.Name, h.Label()), delta)
+}
+
+// damageUnit trừ damage (sau shield) vào troop u và ghi lại đòn đánh của source.
+func (gs *GameSession) damageUnit(u *unit, damage int, source string, delta *tickDelta) {
+	u.hp -= u.fx.Absorb(damage)
+	delta.units = append(delta.units, u.state())
+	delta.text = append(delta.text, fmt.Sprintf("%s hit %s's %s for %d (HP: %d)", source, u.owner.Username, u.troop.Name, damage, u.hp))
+	if u.hp <= 0 {
+		delta.text = append(delta.text, fmt.Sprintf("💀 %s's %s was destroyed", u.owner.Username, u.troop.Name))
+	}
+}
+
+// unitAttack cho troop đánh tower target của đối thủ.
+func (gs *GameSession) unitAttack(u *unit, target int, delta *tickDelta) {
+	defender := gs.opponentOf(u.owner)
+	tower := &defender.Towers[target]
+	alive := make([]bool, len(defender.Towers))
+	for i, t := range defender.Towers {
+		alive[i] = t.HP > 0
+	}
+	splash := engine.Splash(gs.arena, gs.sideOf(defender), defender.Towers, target, u.lane, func(i, damage int) {
+		t := defender.Towers[i]
+		delta.towers = append(delta.towers, network.TowerUpdate{Owner: defender.Username, Index: i, HP: t.HP})
+		delta.text = append(delta.text, fmt.Sprintf("💥 %s splash hit %s's %s for %d (HP: %d)", u.troop.Name, defender.Username, t.Type, damage, t.HP))
+	})
+	h := engine.NewHit(u.troop, utils.CalculateDamage(u.troop.ATK, tower.DEF, false, gs.Rules.CritMultiplier), !u.attacked, u.traveled, &gs.towerFx[u.side.Opponent()][target], splash)
+	damage := h.Damage
+	tower.HP -= damage
+	delta.towers = append(delta.towers, network.TowerUpdate{Owner: defender.Username, Index: target, HP: tower.HP})
+	delta.text = append(delta.text, fmt.Sprintf("💥 %s's %s%s hit %s's %s for %d (HP: %d)", u.owner.Username, u.troop.Name, h.Label(), defender.Username, tower.Type, damage, tower.HP))
+	gs.recordEvent(network.MsgAttackResult, u.owner.Username, network.AttackResult{
+		Attacker: u.owner.Username,
+		Defender: defender.Username,
+		Troop:    u.troop.Name,
+		Tower:    tower.Type,
+		Index:    target,
+		Damage:   damage,
+		TowerHP:  tower.HP,
+		TroopHP:  u.hp,
+	})
+
+	for i, t := range defender.Towers {
+		if alive[i] && t.HP <= 0 {
+			gs.destroyTower(u.owner, defender, i)
+		}
+	}
+}
+
+// tickEffects áp dụng damage theo thời gian và giảm thời gian choáng của mọi
+// troop và tower; chạy mỗi giây.
+func (gs *GameSession) tickEffects(delta *tickDelta) {
+	for _, u := range gs.units {
+		if damage := u.fx.Tick(); damage > 0 && u.hp > 0 {
+			gs.damageUnit(u, damage, "☠️ "+u.fx.DotSource(), delta)
+		}
+	}
+	for _, defender := range []*models.Player{gs.Player1, gs.Player2} {
+		fx := gs.towerFx[gs.sideOf(defender)]
+		for i := range fx {
+			damage := fx[i].Tick()
+			t := &defender.Towers[i]
+			if damage <= 0 || t.HP <= 0 || gs.GameOver {
+				continue
+			}
+			t.HP -= damage
+			delta.towers = append(delta.towers, network.TowerUpdate{Owner: defender.Username, Index: i, HP: t.HP})
+			delta.text = append(delta.text, fmt.Sprintf("☠️ %s hit %s's %s for %d (HP: %d)", fx[i].DotSource(), defender.Username, t.Type, damage, t.HP))
+			if t.HP <= 0 {
+				gs.destroyTower(gs.opponentOf(defender), defender, i)
+			}
+		}
+	}
+}
+
+// towersFire cho mỗi tower còn sống của defender bắn troop đối phương gần nhất
+// trong tầm bắn.
+func (gs *GameSession) towersFire(defender *models.Player, delta *tickDelta) {
+	side := gs.sideOf(defender)
+	for _, b := range gs.arena.Buildings(side) {
+		t := defender.Towers[b.Index]
+		if t.HP <= 0 || gs.towerFx[side][b.Index].Stun > 0 {
+			continue
+		}
+		u := gs.towerTarget(side, b)
+		if u == nil {
+			continue
+		}
+		damage, crit := engine.TowerDamage(t, u.troop, gs.rng.Float64(), gs.Rules)
+		taken := u.fx.Absorb(damage)
+		u.hp -= taken
+		line := fmt.Sprintf("🏹 %s's %s hit %s's %s for %d", defender.Username, t.Type, u.owner.Username, u.troop.Name, taken)
+		if crit {
+			line += " (CRIT!)"
+		}
+		if taken < damage {
+			line += " [shield]"
+		}
+		delta.text = append(delta.text, fmt.Sprintf("%s (HP: %d)", line, u.hp))
+		delta.units = append(delta.units, u.state())
+		if u.hp <= 0 {
+			delta.text = append(delta.text, fmt.Sprintf("💀 %s's %s was destroyed", u.owner.Username, u.troop.Name))
+		}
+	}
+}
+
+// towerTarget chọn troop đối phương còn sống gần building nhất trong tầm bắn.
+func (gs *GameSession) towerTarget(side arena.Side, b arena.Building) *unit {
+	var nearest *unit
+	for _, u := range gs.units {
+		if u.side == side || u.hp <= 0 || !b.Covers(u.lane, u.pos) {
+			continue
+		}
+		if nearest == nil || math.Abs(u.pos-b.Pos) < math.Abs(nearest.pos-b.Pos) {
+			nearest = u
+		}
+	}
+	return nearest
+}
+
+// removeDeadUnits loại các troop hết HP khỏi sân; troop có ability spawn để
+// lại troop mới tại chỗ.
+func (gs *GameSession) removeDeadUnits(delta *tickDelta) {
+	var dead []*unit
+	alive := gs.units[:0]
+	for _, u := range gs.units {
+		if u.hp > 0 {
+			alive = append(alive, u)
+		} else {
+			delta.removed = append(delta.removed, u.id)
+			dead = append(dead, u)
+		}
+	}
+	gs.units = alive
+	for _, u := range dead {
+		spawned, err := engine.DeathSpawns(gs.Rules, u.troop)
+		if err != nil {
+			fmt.Printf("❌ Failed to spawn from %s: %v\n", u.troop.Name, err)
+		}
+		for _, troop := range spawned {
+			s := gs.newUnit(u.owner, troop, u.lane, u.pos)
+			delta.units = append(delta.units, s.state())
+			delta.text = append(delta.text, fmt.Sprintf("🐣 %s's %s appeared from %s", u.owner.Username, troop.Name, u.troop.Name))
+		}
+	}
+}
+
+func (gs *GameSession) unitStates() []network.UnitState {
+	states := make([]network.UnitState, 0, len(gs.units))
+	for _, u := range gs.units {
+		states = append(states, u.state())
+	}
+	return states
+}
+
+// destroyTower thông báo tower index của defender đã bị phá; phá King Tower
+// thì attacker thắng.
+func (gs *GameSession) destroyTower(attacker, defender *models.Player, index int) {
+	gs.announceTowerDestroyed(attacker, defender, index)
+	if defender.Towers[index].Type == engine.KingTower && !gs.GameOver {
+		gs.endGame(engine.GameEnded{Winner: gs.index(attacker), Reason: engine.ReasonKing})
+	}
+}
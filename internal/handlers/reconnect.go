@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+var (
+	// activeSessions maps a username to the match they are currently playing.
+	activeSessions   = make(map[string]*GameSession)
+	activeSessionsMu sync.Mutex
+)
+
+// registerSession ghi nhận trận của hai người chơi; bot không được ghi nhận
+// vì nhiều trận có thể dùng cùng tên bot.
+func registerSession(gs *GameSession) {
+	activeSessionsMu.Lock()
+	for _, p := range []*models.Player{gs.Player1, gs.Player2} {
+		if !gs.isBot(p) {
+			activeSessions[p.Username] = gs
+		}
+	}
+	activeSessionsMu.Unlock()
+}
+
+func unregisterSession(gs *GameSession) {
+	activeSessionsMu.Lock()
+	defer activeSessionsMu.Unlock()
+	for _, name := range []string{gs.Player1.Username, gs.Player2.Username} {
+		if activeSessions[name] == gs {
+			delete(activeSessions, name)
+		}
+	}
+}
+
+// reconnectRequest asks the session loop to swap in a player's new connection.
+type reconnectRequest struct {
+	player *models.Player
+	conn   *network.Conn
+}
+
+// activeSession trả về trận chưa kết thúc của username, nếu có.
+func activeSession(username string) (*GameSession, bool) {
+	activeSessionsMu.Lock()
+	gs, ok := activeSessions[username]
+	activeSessionsMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	select {
+	case <-gs.done:
+		return nil, false
+	default:
+		return gs, true
+	}
+}
+
+// HasActiveSession reports whether username is still playing a match.
+func HasActiveSession(username string) bool {
+	_, ok := activeSession(username)
+	return ok
+}
+
+// ReconnectPlayer hands conn to the match username is still playing, if any.
+// The session loop closes the old connection, swaps the new one in and
+// resyncs it.
+func ReconnectPlayer(username string, conn *network.Conn) bool {
+	gs, ok := activeSession(username)
+	if !ok {
+		return false
+	}
+	conn.SendPDU("info", "🔄 Rejoining your match...")
+
+	select {
+	case gs.reconnects <- reconnectRequest{player: gs.playerByName(username), conn: conn}:
+		return true
+	case <-gs.done:
+		return false
+	}
+}
+
+// handleDisconnect tạm dừng trận đấu trong thời gian chờ p kết nối lại.
+func (gs *GameSession) handleDisconnect(p *models.Player) {
+	if _, ok := gs.disconnected[p]; ok {
+		return
+	}
+	opponent := gs.opponentOf(p)
+	grace := gs.Rules.ReconnectGraceSec
+	fmt.Printf("⚠️ %s disconnected, waiting %ds for reconnect\n", p.Username, grace)
+
+	gs.disconnected[p] = time.Now().Add(gs.Rules.ReconnectGrace())
+	if gs.GameTimer != nil {
+		gs.GameTimer.Pause()
+	}
+	text := fmt.Sprintf("⚠️ %s disconnected. Waiting up to %ds for them to reconnect...", p.Username, grace)
+	notice := network.PlayerConnection{Player: p.Username, GraceSec: grace}
+	gs.connFor(opponent).Send(network.MsgPlayerDisconnected, text, notice)
+	gs.spectate(network.MsgPlayerDisconnected, text, notice)
+}
+
+// handleReconnect thay kết nối của người chơi và tiếp tục trận đấu.
+func (gs *GameSession) handleReconnect(req reconnectRequest) {
+	p := req.player
+	opponent := gs.opponentOf(p)
+
+	// Đóng kết nối cũ; goroutine đọc của nó sẽ bị bỏ qua vì không còn khớp
+	gs.connFor(p).Close()
+	gs.setConn(p, req.conn)
+	gs.startReader(p, req.conn)
+
+	if _, ok := gs.disconnected[p]; ok {
+		delete(gs.disconnected, p)
+		if !gs.isPaused() && gs.GameTimer != nil {
+			gs.GameTimer.Resume()
+		}
+		text := fmt.Sprintf("✅ %s reconnected. The match continues!", p.Username)
+		notice := network.PlayerConnection{Player: p.Username}
+		gs.connFor(opponent).Send(network.MsgPlayerReconnected, text, notice)
+		gs.spectate(network.MsgPlayerReconnected, text, notice)
+	}
+	gs.resync(p)
+	if gs.Mode == models.ModeRealtime {
+		gs.sendHand(p)
+	} else if p == gs.TurnOwner || gs.isBot(gs.TurnOwner) {
+		// Lựa chọn dở dang bị huỷ, lượt bắt đầu lại từ menu; bot đi lại nước
+		// đã bị từ chối trong lúc trận tạm dừng
+		gs.startTurn()
+	}
+}
+
+// resync gửi lại toàn bộ trạng thái trận đấu cho người chơi vừa kết nối lại.
+func (gs *GameSession) resync(p *models.Player) {
+	conn := gs.connFor(p)
+	opponent := gs.opponentOf(p)
+
+	resync := network.StateResync{
+		Player1:   gs.Player1.Username,
+		Player2:   gs.Player2.Username,
+		TurnOwner: gs.TurnOwner.Username,
+		You:       statusSnapshot(p),
+		Opponent:  statusSnapshot(opponent),
+	}
+	// Không lộ bài trên tay đối thủ
+	resync.Opponent.Troops = []network.TroopCard{}
+	resync.Opponent.Next = nil
+	if gs.GameTimer != nil {
+		resync.TimeLeftSec = int(gs.GameTimer.TimeRemaining().Seconds())
+	}
+	if gs.Mode == models.ModeRealtime {
+		resync.TurnOwner = ""
+		resync.Units = gs.unitStates()
+	}
+
+	text := fmt.Sprintf("🔄 Reconnected to your match against %s.", opponent.Username)
+	for _, t := range opponent.Towers {
+		text += fmt.Sprintf("\n🏰 %s's %s HP: %d", opponent.Username, t.Type, t.HP)
+	}
+	for _, t := range p.Towers {
+		text += fmt.Sprintf("\n🛡️ Your %s HP: %d", t.Type, t.HP)
+	}
+	conn.Send(network.MsgStateResync, text, resync)
+}
+
+func (gs *GameSession) playerByName(username string) *models.Player {
+	if gs.Player1.Username == username {
+		return gs.Player1
+	}
+	return gs.Player2
+}
+
+func (gs *GameSession) opponentOf(p *models.Player) *models.Player {
+	if p == gs.Player1 {
+		return gs.Player2
+	}
+	return gs.Player1
+}
+
+func (gs *GameSession) connFor(p *models.Player) *network.Conn {
+	if p == gs.Player1 {
+		return gs.Conn1
+	}
+	return gs.Conn2
+}
+
+func (gs *GameSession) setConn(p *models.Player, conn *network.Conn) {
+	if p == gs.Player1 {
+		gs.Conn1 = conn
+	} else {
+		gs.Conn2 = conn
+	}
+}
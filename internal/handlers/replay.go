@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/replay"
+)
+
+// ReplayDir là thư mục lưu file replay của mọi trận đấu. Có thể thay đổi khi
+// khởi động server.
+var ReplayDir = filepath.Join("data", "replays")
+
+const (
+	// ReplayHelp là hướng dẫn lệnh replay cho client văn bản.
+	ReplayHelp = "🎬 Type 'replay' to list your recent matches, or 'replay <id> [speed]' to watch one again."
+	// maxReplayList là số replay tối đa trong danh sách.
+	maxReplayList = 10
+	// Giới hạn tốc độ phát replay.
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 16
+)
+
+// startReplay mở file replay và ghi trạng thái ban đầu của trận đấu.
+func (gs *GameSession) startReplay() {
+	h := replay.Header{
+		Player1:   gs.Player1.Username,
+		Player2:   gs.Player2.Username,
+		Mode:      gs.Mode.String(),
+		Seed:      gs.Seed,
+		StartedAt: time.Now(),
+		Rules:     gs.Rules,
+		Players:   []replay.PlayerState{initialState(gs.Player1), initialState(gs.Player2)},
+	}
+	rec, err := replay.Create(ReplayDir, h)
+	if err != nil {
+		fmt.Printf("❌ Failed to create replay: %v\n", err)
+		return
+	}
+	gs.replay = rec
+}
+
+func initialState(p *models.Player) replay.PlayerState {
+	state := replay.PlayerState{StatusSnapshot: statusSnapshot(p), DrawPile: []network.TroopCard{}}
+	for _, t := range p.DrawPile {
+		state.DrawPile = append(state.DrawPile, troopCard(t))
+	}
+	return state
+}
+
+// recordReplay ghi một hành động hoặc sự kiện vào replay nếu trận có replay.
+func (gs *GameSession) recordReplay(kind, pduType, player, text string, data interface{}) {
+	if gs.replay == nil {
+		return
+	}
+	if err := gs.replay.Record(kind, pduType, player, text, data); err != nil {
+		fmt.Printf("❌ Failed to record replay, stopping: %v\n", err)
+		gs.closeReplay()
+	}
+}
+
+func (gs *GameSession) closeReplay() {
+	if gs.replay == nil {
+		return
+	}
+	if err := gs.replay.Close(); err != nil {
+		fmt.Printf("❌ Failed to close replay: %v\n", err)
+	}
+	gs.replay = nil
+}
+
+// HandleReplayCommand xử lý lệnh liệt kê và xem lại replay ngoài trận; trả về
+// false nếu PDU không phải lệnh replay. Lỗi trả về là lỗi đọc khi client
+// ngắt kết nối trong lúc xem.
+func HandleReplayCommand(conn *network.Conn, pdu network.PDU, player *models.Player) (bool, error) {
+	var req network.ReplayRequest
+	switch {
+	case pdu.Type == network.MsgReplay:
+		if pdu.HasData() {
+			if err := pdu.DecodeData(&req); err != nil {
+				conn.Reply(pdu, "error", "❌ Invalid replay request.", nil)
+				return true, nil
+			}
+		}
+	default:
+		fields := strings.Fields(pdu.Payload)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "replay") {
+			return false, nil
+		}
+		if len(fields) > 1 {
+			req.ID = fields[1]
+		}
+		if len(fields) > 2 {
+			speed, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				conn.Reply(pdu, "error", "❗ Usage: replay <id> [speed]", nil)
+				return true, nil
+			}
+			req.Speed = speed
+		}
+	}
+
+	if req.ID == "" {
+		sendReplayList(conn, pdu, player.Username)
+		return true, nil
+	}
+	h, entries, err := replay.Load(ReplayDir, req.ID)
+	if err == nil && h.Player1 != player.Username && h.Player2 != player.Username {
+		// Replay chứa seed và thứ tự bài nên chỉ người đã chơi trận đó được xem
+		err = replay.ErrNotFound
+	}
+	if errors.Is(err, replay.ErrNotFound) {
+		conn.Reply(pdu, "error", "❌ No replay with id "+req.ID+".", nil)
+		return true, nil
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to load replay %s: %v\n", req.ID, err)
+		conn.Reply(pdu, "error", "❌ Failed to load replay.", nil)
+		return true, nil
+	}
+	return true, playReplay(conn, pdu, h, entries, req.Speed)
+}
+
+func sendReplayList(conn *network.Conn, req network.PDU, username string) {
+	headers, err := replay.List(ReplayDir, username)
+	if err != nil {
+		fmt.Printf("❌ Failed to list replays: %v\n", err)
+		conn.Reply(req, "error", "❌ Failed to list replays.", nil)
+		return
+	}
+	if len(headers) > maxReplayList {
+		headers = headers[:maxReplayList]
+	}
+	list := network.ReplayList{Replays: []network.ReplayInfo{}}
+	text := "🎬 You have no recorded matches yet."
+	if len(headers) > 0 {
+		text = "🎬 Your recent matches:"
+	}
+	for _, h := range headers {
+		list.Replays = append(list.Replays, network.ReplayInfo{ID: h.ID, Player1: h.Player1, Player2: h.Player2, Mode: h.Mode, StartedAt: h.StartedAt})
+		text += fmt.Sprintf("\n%s: %s vs %s (%s)", h.ID, h.Player1, h.Player2, h.Mode)
+	}
+	conn.Reply(req, network.MsgReplayList, text, list)
+}
+
+// clampSpeed đưa tốc độ phát về trong giới hạn; 0 hoặc NaN nghĩa là tốc độ
+// thường.
+func clampSpeed(speed float64) float64 {
+	switch {
+	case speed == 0 || math.IsNaN(speed):
+		return 1
+	case speed < minReplaySpeed:
+		return minReplaySpeed
+	case speed > maxReplaySpeed:
+		return maxReplaySpeed
+	}
+	return speed
+}
+
+// playReplay phát lại các sự kiện của trận theo đúng nhịp đã ghi, nhanh hoặc
+// chậm hơn theo speed. Trong lúc xem client có thể gõ 'speed <x>' hoặc 'stop'.
+func playReplay(conn *network.Conn, req network.PDU, h replay.Header, entries []replay.Entry, speed float64) error {
+	speed = clampSpeed(speed)
+	conn.Reply(req, network.MsgReplayStart,
+		fmt.Sprintf("🎬 Replay %s: %s vs %s (%s) at %gx. Type 'speed <x>' to change speed or 'stop' to stop.",
+			h.ID, h.Player1, h.Player2, h.Mode, speed),
+		h)
+
+	reads, stopReading := conn.ReadInBackground()
+	defer stopReading()
+
+	var played int64 // mốc thời gian (ms) của trận đã phát tới
+	for i := 0; i < len(entries); {
+		e := entries[i]
+		if e.Kind != replay.KindEvent {
+			i++
+			continue
+		}
+		gap := time.Duration(float64(time.Duration(e.AtMs-played)*time.Millisecond) / speed)
+		waitStart := time.Now()
+		timer := time.NewTimer(gap)
+		select {
+		case <-timer.C:
+			var data interface{}
+			if len(e.Data) > 0 {
+				data = e.Data
+			}
+			conn.Send(e.Type, e.Text, data)
+			played = e.AtMs
+			i++
+		case r := <-reads:
+			timer.Stop()
+			if r.Err != nil {
+				return r.Err
+			}
+			// Phần thời gian đã chờ được tính theo tốc độ cũ
+			played += int64(float64(time.Since(waitStart).Milliseconds()) * speed)
+			if played > e.AtMs {
+				played = e.AtMs
+			}
+			var stop bool
+			speed, stop = replayControl(conn, r.PDU, speed)
+			if stop {
+				conn.Reply(r.PDU, network.MsgReplayEnd, "⏹️ Replay stopped.", nil)
+				return nil
+			}
+		}
+	}
+	conn.Send(network.MsgReplayEnd, "🎬 End of replay.", nil)
+	return nil
+}
+
+// replayControl xử lý lệnh của client trong lúc xem replay và trả về tốc độ mới.
+func replayControl(conn *network.Conn, pdu network.PDU, speed float64) (float64, bool) {
+	if pdu.Type == network.MsgReplayControl {
+		var ctl network.ReplayControl
+		if err := pdu.DecodeData(&ctl); err != nil {
+			conn.Reply(pdu, "error", "❌ Invalid replay control.", nil)
+			return speed, false
+		}
+		if ctl.Stop {
+			return speed, true
+		}
+		if ctl.Speed != 0 {
+			if !(ctl.Speed > 0) {
+				conn.Reply(pdu, "error", "❌ Invalid replay speed.", nil)
+				return speed, false
+			}
+			speed = clampSpeed(ctl.Speed)
+		}
+		conn.Reply(pdu, "info", fmt.Sprintf("⏩ Replay speed %gx.", speed), nil)
+		return speed, false
+	}
+
+	fields := strings.Fields(pdu.Payload)
+	switch {
+	case len(fields) == 1 && (strings.EqualFold(fields[0], "stop") || strings.EqualFold(fields[0], "leave")):
+		return speed, true
+	case len(fields) == 2 && strings.EqualFold(fields[0], "speed"):
+		if v, err := strconv.ParseFloat(fields[1], 64); err == nil && v > 0 {
+			speed = clampSpeed(v)
+			conn.Reply(pdu, "info", fmt.Sprintf("⏩ Replay speed %gx.", speed), nil)
+			return speed, false
+		}
+	}
+	conn.Reply(pdu, "error", "🎬 Watching a replay. Type 'speed <x>' or 'stop'.", nil)
+	return speed, false
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/replay"
+)
+
+func TestClampSpeed(t *testing.T) {
+	tests := []struct {
+		speed, want float64
+	}{
+		{0, 1},
+		{math.NaN(), 1},
+		{2, 2},
+		{-1, minReplaySpeed},
+		{0.1, minReplaySpeed},
+		{100, maxReplaySpeed},
+		{math.Inf(1), maxReplaySpeed},
+	}
+	for _, tt := range tests {
+		if got := clampSpeed(tt.speed); got != tt.want {
+			t.Errorf("clampSpeed(%v) = %v, want %v", tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestReplayOnlyForPlayers(t *testing.T) {
+	ReplayDir = t.TempDir()
+	h := replay.Header{Player1: "alice", Player2: "bob", Mode: models.ModeUntimed.String(), Seed: 42, StartedAt: time.Now()}
+	rec, err := replay.Create(ReplayDir, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	headers, err := replay.List(ReplayDir, "alice")
+	if err != nil || len(headers) != 1 {
+		t.Fatalf("List = %v, %v; want one replay", headers, err)
+	}
+	if headers[0].Seed != h.Seed {
+		t.Fatalf("replay seed = %d, want %d", headers[0].Seed, h.Seed)
+	}
+	id := headers[0].ID
+
+	watch := func(username string) network.PDU {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		server, client := network.NewConn(a), network.NewConn(b)
+		go HandleReplayCommand(server, network.PDU{Type: "input", Payload: "replay " + id}, &models.Player{Username: username})
+		pdu, err := client.ReadPDU()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pdu
+	}
+	if pdu := watch("eve"); pdu.Type != "error" || !strings.Contains(pdu.Payload, "No replay") {
+		t.Fatalf("outsider got %q %q, want not found", pdu.Type, pdu.Payload)
+	}
+	for _, player := range []string{"alice", "bob"} {
+		if pdu := watch(player); pdu.Type != network.MsgReplayStart {
+			t.Fatalf("%s got %q %q, want replay start", player, pdu.Type, pdu.Payload)
+		}
+	}
+}
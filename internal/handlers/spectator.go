@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/replay"
+)
+
+// SpectateHelp là hướng dẫn lệnh xem trận cho client văn bản.
+const SpectateHelp = "👀 Type 'matches' to list live matches, or 'spectate <player>' to watch one."
+
+// Thông báo gửi cho người xem khi họ phải rời trận.
+const (
+	spectateEndedText   = "📺 The match is over. Back to the menu."
+	spectateDroppedText = "⚠️ You fell too far behind the match and stopped watching. Back to the menu."
+)
+
+// spectatorQueueSize là số PDU tối đa chờ gửi cho một người xem; người xem
+// đọc chậm hơn thế bị bỏ khỏi trận để không làm chậm vòng lặp trận đấu.
+const spectatorQueueSize = 64
+
+// spectator là một kết nối đang xem trận; chỉ vòng lặp của trận đấu đọc và
+// sửa danh sách người xem.
+type spectator struct {
+	username string
+	conn     *network.Conn
+	out      chan spectatorMessage // hàng đợi gửi, vòng lặp trận đấu đóng khi bỏ người xem
+	done     chan struct{}         // đóng khi người xem rời trận, bị bỏ hoặc trận kết thúc
+	reason   string                // thông báo cho người xem, gán trước khi đóng done
+}
+
+// spectatorMessage là một PDU chờ goroutine ghi của người xem gửi đi.
+type spectatorMessage struct {
+	req     network.PDU
+	pduType string
+	text    string
+	data    interface{}
+}
+
+// spectatorInput là một lệnh của người xem gửi cho vòng lặp trận đấu.
+type spectatorInput struct {
+	spectator *spectator
+	pdu       network.PDU
+}
+
+// ErrMatchNotFound là lỗi khi người chơi cần xem không ở trong trận nào.
+var ErrMatchNotFound = errors.New("no live match for that player")
+
+// LiveMatches liệt kê các trận đang diễn ra.
+func LiveMatches() []network.LiveMatch {
+	activeSessionsMu.Lock()
+	seen := make(map[*GameSession]bool)
+	var matches []network.LiveMatch
+	for _, gs := range activeSessions {
+		if seen[gs] {
+			continue
+		}
+		seen[gs] = true
+		matches = append(matches, network.LiveMatch{
+			Player1:    gs.Player1.Username,
+			Player2:    gs.Player2.Username,
+			Mode:       gs.Mode.String(),
+			Spectators: int(gs.spectatorCount.Load()),
+		})
+	}
+	activeSessionsMu.Unlock()
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Player1 < matches[j].Player1 })
+	return matches
+}
+
+// HandleMatchesCommand trả lời lệnh liệt kê trận đang diễn ra; trả về false
+// nếu PDU không phải lệnh đó.
+func HandleMatchesCommand(conn *network.Conn, pdu network.PDU) bool {
+	if pdu.Type != network.MsgListMatches && !strings.EqualFold(strings.TrimSpace(pdu.Payload), "matches") {
+		return false
+	}
+	list := network.MatchList{Matches: LiveMatches()}
+	if list.Matches == nil {
+		list.Matches = []network.LiveMatch{}
+	}
+	text := "📺 No live matches right now."
+	if len(list.Matches) > 0 {
+		text = "📺 Live matches:"
+		for _, m := range list.Matches {
+			text += fmt.Sprintf("\n%s vs %s (%s, %d watching)", m.Player1, m.Player2, m.Mode, m.Spectators)
+		}
+	}
+	conn.Reply(pdu, network.MsgMatchList, text, list)
+	return true
+}
+
+// ParseSpectateRequest nhận lệnh "spectate <player>" hoặc PDU spectate và
+// trả về tên người chơi cần xem.
+func ParseSpectateRequest(pdu network.PDU) (player string, ok bool) {
+	if pdu.Type == network.MsgSpectate {
+		var req network.Spectate
+		if err := pdu.DecodeData(&req); err != nil {
+			return "", true
+		}
+		return strings.TrimSpace(req.Player), true
+	}
+	fields := strings.Fields(pdu.Payload)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "spectate") {
+		return "", false
+	}
+	if len(fields) < 2 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+// Spectate cho conn xem trận mà player đang chơi cho tới khi người xem gõ
+// 'leave', bị bỏ vì đọc quá chậm hoặc trận kết thúc. Người xem nhận cùng luồng sự kiện như người
+// chơi nhưng không thấy bài trên tay trừ khi luật trận cho phép. Trả về lỗi
+// đọc nếu người xem ngắt kết nối.
+func Spectate(conn *network.Conn, username, player string) error {
+	activeSessionsMu.Lock()
+	gs, ok := activeSessions[player]
+	activeSessionsMu.Unlock()
+	if !ok {
+		return ErrMatchNotFound
+	}
+
+	sp := &spectator{
+		username: username,
+		conn:     conn,
+		out:      make(chan spectatorMessage, spectatorQueueSize),
+		done:     make(chan struct{}),
+	}
+	select {
+	case gs.spectates <- sp:
+	case <-gs.done:
+		return ErrMatchNotFound
+	}
+
+	// Ghi trong goroutine riêng; written đóng khi hàng đợi đã gửi hết
+	written := make(chan struct{})
+	go sp.write(written)
+
+	// Đọc lệnh của người xem trong goroutine riêng để dừng được khi trận kết thúc
+	reads, stopReading := conn.ReadInBackground()
+	defer stopReading()
+
+	for {
+		select {
+		case <-sp.done:
+			<-written
+			conn.SendPDU("info", sp.reason)
+			return nil
+		case r := <-reads:
+			if r.Err == nil && !isLeave(r.PDU) {
+				select {
+				case gs.spectatorInputs <- spectatorInput{spectator: sp, pdu: r.PDU}:
+				case <-sp.done:
+				}
+				continue
+			}
+			select {
+			case gs.spectatorLeaves <- sp:
+			case <-sp.done:
+			}
+			if r.Err != nil {
+				return r.Err
+			}
+			<-written
+			conn.Reply(r.PDU, "info", "👋 You stopped watching.", nil)
+			return nil
+		}
+	}
+}
+
+// write gửi các PDU trong hàng đợi cho tới khi vòng lặp trận đấu đóng nó.
+// Sau lỗi ghi đầu tiên các PDU còn lại bị bỏ; goroutine đọc sẽ thấy kết nối
+// đã hỏng.
+func (sp *spectator) write(written chan<- struct{}) {
+	defer close(written)
+	var failed bool
+	for msg := range sp.out {
+		if failed {
+			continue
+		}
+		if err := sp.conn.Reply(msg.req, msg.pduType, msg.text, msg.data); err != nil {
+			fmt.Printf("❌ Failed to send to spectator %s: %v\n", sp.username, err)
+			failed = true
+		}
+	}
+}
+
+func isLeave(pdu network.PDU) bool {
+	return pdu.Type == network.MsgStopSpectating || strings.EqualFold(strings.TrimSpace(pdu.Payload), "leave")
+}
+
+// addSpectator thêm người xem và gửi cho họ toàn bộ trạng thái trận đấu.
+func (gs *GameSession) addSpectator(sp *spectator) {
+	gs.Broadcast(fmt.Sprintf("👀 %s is now watching.", sp.username))
+	gs.spectators = append(gs.spectators, sp)
+	gs.spectatorCount.Add(1)
+	gs.sendSpectateState(sp, network.PDU{})
+}
+
+// removeSpectator bỏ người xem khỏi trận; reason là thông báo cho người xem
+// nếu họ không tự rời trận.
+func (gs *GameSession) removeSpectator(sp *spectator, reason string) {
+	for i, other := range gs.spectators {
+		if other == sp {
+			gs.spectators = append(gs.spectators[:i:i], gs.spectators[i+1:]...)
+			gs.spectatorCount.Add(-1)
+			sp.reason = reason
+			close(sp.out)
+			close(sp.done)
+			return
+		}
+	}
+}
+
+// endSpectating trả mọi người xem về menu khi trận kết thúc.
+func (gs *GameSession) endSpectating() {
+	for _, sp := range gs.spectators {
+		sp.reason = spectateEndedText
+		close(sp.out)
+		close(sp.done)
+	}
+	gs.spectators = nil
+	gs.spectatorCount.Store(0)
+}
+
+// handleSpectatorInput xử lý lệnh của người xem; chỉ có 'status'.
+func (gs *GameSession) handleSpectatorInput(in spectatorInput) {
+	if in.pdu.Type == network.MsgStatusSnapshot || strings.EqualFold(strings.TrimSpace(in.pdu.Payload), "status") {
+		gs.sendSpectateState(in.spectator, in.pdu)
+		return
+	}
+	gs.sendSpectator(in.spectator, in.pdu, "error", "👀 You are spectating. Type 'status' to see the board or 'leave' to stop watching.", nil)
+}
+
+// sendSpectateState gửi trạng thái của cả hai người chơi cho người xem.
+func (gs *GameSession) sendSpectateState(sp *spectator, req network.PDU) {
+	state := network.SpectateState{
+		Mode:    gs.Mode.String(),
+		Player1: statusSnapshot(gs.Player1),
+		Player2: statusSnapshot(gs.Player2),
+	}
+	if gs.Mode == models.ModeRealtime {
+		state.Units = gs.unitStates()
+	} else {
+		state.TurnOwner = gs.TurnOwner.Username
+	}
+	if gs.GameTimer != nil {
+		state.TimeLeftSec = int(gs.GameTimer.TimeRemaining().Seconds())
+	}
+	if !gs.Rules.SpectatorHands {
+		for _, s := range []*network.StatusSnapshot{&state.Player1, &state.Player2} {
+			s.Troops = []network.TroopCard{}
+			s.Next = nil
+		}
+	}
+
+	text := fmt.Sprintf("📺 %s vs %s (%s)", gs.Player1.Username, gs.Player2.Username, gs.Mode)
+	for _, s := range []network.StatusSnapshot{state.Player1, state.Player2} {
+		for _, t := range s.Towers {
+			text += fmt.Sprintf("\n🏰 %s's %s HP: %d", s.Username, t.Type, t.HP)
+		}
+		for _, c := range s.Troops {
+			text += fmt.Sprintf("\n🃏 %s holds %s", s.Username, c.Name)
+		}
+	}
+	if state.TurnOwner != "" {
+		text += fmt.Sprintf("\n🎯 %s's turn", state.TurnOwner)
+	}
+	text += "\nType 'status' to refresh or 'leave' to stop watching."
+	gs.sendSpectator(sp, req, network.MsgSpectateState, text, state)
+}
+
+// sendSpectator xếp một PDU vào hàng đợi của sp mà không chặn; người xem có
+// hàng đợi đầy bị bỏ khỏi trận. Trả về false nếu sp đã bị bỏ.
+func (gs *GameSession) sendSpectator(sp *spectator, req network.PDU, pduType, text string, data interface{}) bool {
+	select {
+	case <-sp.done:
+		// Lệnh đến sau khi người xem đã rời trận
+		return false
+	default:
+	}
+	select {
+	case sp.out <- spectatorMessage{req: req, pduType: pduType, text: text, data: data}:
+		return true
+	default:
+		fmt.Printf("⚠️ Dropping spectator %s: too far behind the match\n", sp.username)
+		gs.removeSpectator(sp, spectateDroppedText)
+		return false
+	}
+}
+
+// spectate gửi một sự kiện công khai cho mọi người xem và ghi nó vào replay.
+func (gs *GameSession) spectate(pduType, text string, data interface{}) {
+	gs.recordReplay(replay.KindEvent, pduType, "", text, data)
+	// Duyệt bản sao vì sendSpectator có thể bỏ người xem khỏi gs.spectators
+	for _, sp := range slices.Clone(gs.spectators) {
+		gs.sendSpectator(sp, network.PDU{}, pduType, text, data)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"testing"
+
+	"net-centric-clash-royale/internal/network"
+)
+
+func TestSlowSpectatorIsDropped(t *testing.T) {
+	gs := &GameSession{}
+	sp := &spectator{
+		username: "watcher",
+		out:      make(chan spectatorMessage, spectatorQueueSize),
+		done:     make(chan struct{}),
+	}
+	gs.spectators = append(gs.spectators, sp)
+	gs.spectatorCount.Add(1)
+
+	// Không có goroutine ghi: hàng đợi đầy sau spectatorQueueSize PDU
+	for i := 0; i < spectatorQueueSize; i++ {
+		if !gs.sendSpectator(sp, network.PDU{}, "info", "tick", nil) {
+			t.Fatalf("send %d dropped the spectator before the queue was full", i)
+		}
+	}
+	if gs.sendSpectator(sp, network.PDU{}, "info", "tick", nil) {
+		t.Fatal("send to a full queue succeeded")
+	}
+	select {
+	case <-sp.done:
+	default:
+		t.Fatal("dropped spectator was not told to leave")
+	}
+	if sp.reason != spectateDroppedText {
+		t.Errorf("dropped spectator is told %q, want %q", sp.reason, spectateDroppedText)
+	}
+	if len(gs.spectators) != 0 || gs.spectatorCount.Load() != 0 {
+		t.Fatalf("spectators = %d (count %d), want 0", len(gs.spectators), gs.spectatorCount.Load())
+	}
+	if gs.sendSpectator(sp, network.PDU{}, "info", "late", nil) {
+		t.Fatal("send after the spectator left succeeded")
+	}
+}
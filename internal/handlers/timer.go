@@ -2,43 +2,73 @@ package handlers
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
-const (
-	// GameDuration defines the total duration of a game in minutes.
-	GameDuration = 3 * time.Minute
-)
-
 // GameTimer holds the state of the game timer.
 type GameTimer struct {
+	mu        sync.Mutex
 	startTime time.Time
 	duration  time.Duration
+	pausedAt  time.Time // khác zero khi timer đang tạm dừng
 }
 
-// NewGameTimer creates and returns a new GameTimer instance.
-// It initializes the timer with the predefined GameDuration.
-func NewGameTimer() *GameTimer {
+// NewGameTimer creates and returns a new GameTimer instance that runs for
+// duration once started.
+func NewGameTimer(duration time.Duration) *GameTimer {
 	return &GameTimer{
-		duration: GameDuration,
+		duration: duration,
 	}
 }
 
 // Start records the current time as the beginning of the game.
 func (gt *GameTimer) Start() {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
 	gt.startTime = time.Now()
+	gt.pausedAt = time.Time{}
+}
+
+// Pause stops the clock, e.g. while a disconnected player may still return.
+func (gt *GameTimer) Pause() {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if gt.pausedAt.IsZero() {
+		gt.pausedAt = time.Now()
+	}
+}
+
+// Resume continues the clock after Pause; paused time does not count.
+func (gt *GameTimer) Resume() {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if !gt.pausedAt.IsZero() {
+		gt.startTime = gt.startTime.Add(time.Since(gt.pausedAt))
+		gt.pausedAt = time.Time{}
+	}
+}
+
+// elapsed trả về thời gian đã chơi, không tính thời gian tạm dừng.
+func (gt *GameTimer) elapsed() time.Duration {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if !gt.pausedAt.IsZero() {
+		return gt.pausedAt.Sub(gt.startTime)
+	}
+	return time.Since(gt.startTime)
 }
 
 // IsTimeUp checks if the game duration has elapsed since the timer started.
 // It returns true if the current time is past the end time, false otherwise.
 func (gt *GameTimer) IsTimeUp() bool {
-	return time.Since(gt.startTime) >= gt.duration
+	return gt.elapsed() >= gt.duration
 }
 
 // TimeRemaining calculates and returns the time left in the game.
 // If the game has already ended (time is up), it returns 0.
 func (gt *GameTimer) TimeRemaining() time.Duration {
-	elapsed := time.Since(gt.startTime)
+	elapsed := gt.elapsed()
 	if elapsed >= gt.duration {
 		return 0
 	}
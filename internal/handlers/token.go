@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionTokenTTL là thời gian sống của một session token.
+	SessionTokenTTL = 24 * time.Hour
+	// tokenSweepInterval là chu kỳ dọn các token đã hết hạn.
+	tokenSweepInterval = 10 * time.Minute
+)
+
+type sessionToken struct {
+	username string
+	expires  time.Time
+}
+
+// TokenStore keeps opaque session tokens issued after a successful login.
+type TokenStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]sessionToken
+}
+
+// NewTokenStore creates a store whose tokens expire after ttl and starts a
+// background sweeper for expired tokens.
+func NewTokenStore(ttl time.Duration) *TokenStore {
+	store := &TokenStore{
+		ttl:    ttl,
+		tokens: make(map[string]sessionToken),
+	}
+	go func() {
+		for {
+			time.Sleep(tokenSweepInterval)
+			store.sweep()
+		}
+	}()
+	return store
+}
+
+// Issue creates a new token for username.
+func (s *TokenStore) Issue(username string) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	expires := time.Now().Add(s.ttl)
+
+	s.mu.Lock()
+	s.tokens[token] = sessionToken{username: username, expires: expires}
+	s.mu.Unlock()
+	return token, expires, nil
+}
+
+// Lookup returns the username a valid token belongs to. Expired tokens are removed.
+func (s *TokenStore) Lookup(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(t.expires) {
+		delete(s.tokens, token)
+		return "", false
+	}
+	return t.username, true
+}
+
+// Revoke invalidates a single token.
+func (s *TokenStore) Revoke(token string) {
+	s.mu.Lock()
+	delete(s.tokens, token)
+	s.mu.Unlock()
+}
+
+// RevokeUser invalidates every token issued to username.
+func (s *TokenStore) RevokeUser(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, t := range s.tokens {
+		if t.username == username {
+			delete(s.tokens, token)
+		}
+	}
+}
+
+func (s *TokenStore) sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, t := range s.tokens {
+		if now.After(t.expires) {
+			delete(s.tokens, token)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/storage"
+)
+
+// UpgradeHelp là hướng dẫn lệnh upgrade cho client văn bản.
+const UpgradeHelp = "⬆️ Type 'upgrade' to see card and tower levels, or 'upgrade <card or tower>' to level one up."
+
+// AddGold cộng gold cho người chơi.
+func AddGold(player *models.Player, amount int) {
+	player.Gold += amount
+}
+
+// progressOf trả về tiến trình của name trong levels; chưa có thì là cấp 1.
+func progressOf(levels map[string]models.Progress, name string) models.Progress {
+	p := levels[name]
+	if p.Level < 1 {
+		p.Level = 1
+	}
+	return p
+}
+
+// statMultiplier trả về hệ số chỉ số của level theo curve; level vượt quá
+// curve dùng bậc cuối cùng.
+func statMultiplier(curve []models.LevelStep, level int) float64 {
+	if len(curve) == 0 || level < 1 {
+		return 1
+	}
+	if level > len(curve) {
+		level = len(curve)
+	}
+	if curve[level-1].Stat <= 0 {
+		return 1
+	}
+	return curve[level-1].Stat
+}
+
+func scale(v int, m float64) int {
+	return int(float64(v) * m)
+}
+
+// leveledTroop trả về troop với HP/ATK/DEF theo cấp card của người chơi.
+func leveledTroop(p *models.Player, t models.Troop, curves models.LevelCurves) models.Troop {
+	m := statMultiplier(curves.Cards, progressOf(p.CardLevels, t.Name).Level)
+	t.HP, t.ATK, t.DEF = scale(t.HP, m), scale(t.ATK, m), scale(t.DEF, m)
+	return t
+}
+
+// applyTowerLevels nâng chỉ số các tower (vừa nạp từ tower.json) theo cấp tower của người chơi.
+func applyTowerLevels(p *models.Player, curves models.LevelCurves) {
+	for i := range p.Towers {
+		t := &p.Towers[i]
+		m := statMultiplier(curves.Towers, progressOf(p.TowerLevels, t.Type).Level)
+		t.HP, t.ATK, t.DEF = scale(t.HP, m), scale(t.ATK, m), scale(t.DEF, m)
+	}
+}
+
+// gainCardExp cộng EXP của troop cho card tương ứng mỗi khi card được chơi.
+func gainCardExp(p *models.Player, troop models.Troop) {
+	if p.CardLevels == nil {
+		p.CardLevels = make(map[string]models.Progress)
+	}
+	prog := progressOf(p.CardLevels, troop.Name)
+	prog.EXP += troop.EXP
+	p.CardLevels[troop.Name] = prog
+}
+
+// gainTowerExp cộng EXP của mỗi tower còn đứng cuối trận cho loại tower đó.
+func gainTowerExp(p *models.Player) {
+	if p.TowerLevels == nil {
+		p.TowerLevels = make(map[string]models.Progress)
+	}
+	for _, t := range p.Towers {
+		if t.HP <= 0 {
+			continue
+		}
+		prog := progressOf(p.TowerLevels, t.Type)
+		prog.EXP += t.EXP
+		p.TowerLevels[t.Type] = prog
+	}
+}
+
+// upgrade nâng name lên một cấp, trả EXP của chính nó và gold của người chơi.
+func upgrade(p *models.Player, levels map[string]models.Progress, curve []models.LevelStep, name string) error {
+	prog := progressOf(levels, name)
+	if prog.Level >= len(curve) {
+		return fmt.Errorf("%s is already at max level", name)
+	}
+	step := curve[prog.Level]
+	if prog.EXP < step.EXP {
+		return fmt.Errorf("%s needs %d EXP, has %d", name, step.EXP, prog.EXP)
+	}
+	if p.Gold < step.Gold {
+		return fmt.Errorf("need %d gold, have %d", step.Gold, p.Gold)
+	}
+	prog.EXP -= step.EXP
+	prog.Level++
+	p.Gold -= step.Gold
+	levels[name] = prog
+	return nil
+}
+
+func levelStates(levels map[string]models.Progress, curve []models.LevelStep, names []string) []network.LevelState {
+	states := make([]network.LevelState, 0, len(names))
+	for _, name := range names {
+		prog := progressOf(levels, name)
+		state := network.LevelState{Name: name, Level: prog.Level, EXP: prog.EXP, Max: prog.Level >= len(curve)}
+		if !state.Max {
+			state.NextEXP = curve[prog.Level].EXP
+			state.NextGold = curve[prog.Level].Gold
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// HandleUpgradeCommand xử lý lệnh xem và nâng cấp card / tower ngoài trận;
+// trả về false nếu PDU không phải lệnh upgrade.
+func HandleUpgradeCommand(conn *network.Conn, pdu network.PDU, player *models.Player, store storage.PlayerStore, rules models.Ruleset) bool {
+	var name string
+	switch {
+	case pdu.Type == network.MsgUpgrade:
+		var req network.UpgradeRequest
+		if pdu.HasData() {
+			if err := pdu.DecodeData(&req); err != nil {
+				conn.Reply(pdu, "error", "❌ Invalid upgrade request.", nil)
+				return true
+			}
+		}
+		name = strings.TrimSpace(req.Name)
+	default:
+		fields := strings.Fields(pdu.Payload)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "upgrade") {
+			return false
+		}
+		name = strings.TrimSpace(strings.TrimSpace(pdu.Payload)[len(fields[0]):])
+	}
+
+	curves := rules.Levels
+	ensureCollection(player, rules.Troops, rules.DeckSize)
+
+	var cards []string
+	for card := range player.Collection {
+		cards = append(cards, card)
+	}
+	sort.Strings(cards)
+	var towers []string
+	for _, t := range player.Towers {
+		if !hasName(towers, t.Type) {
+			towers = append(towers, t.Type)
+		}
+	}
+
+	text := ""
+	if name != "" {
+		if player.CardLevels == nil {
+			player.CardLevels = make(map[string]models.Progress)
+		}
+		if player.TowerLevels == nil {
+			player.TowerLevels = make(map[string]models.Progress)
+		}
+		var err error
+		switch {
+		case matchName(cards, &name):
+			err = upgrade(player, player.CardLevels, curves.Cards, name)
+		case matchName(towers, &name):
+			err = upgrade(player, player.TowerLevels, curves.Towers, name)
+		default:
+			err = fmt.Errorf("unknown card or tower %q", name)
+		}
+		if err != nil {
+			conn.Reply(pdu, "error", "❌ Cannot upgrade: "+err.Error()+".", nil)
+			return true
+		}
+		if err := store.Put(player); err != nil {
+			fmt.Printf("❌ Failed to save upgrade for %s: %v\n", player.Username, err)
+			conn.Reply(pdu, "error", "❌ Failed to save upgrade.", nil)
+			return true
+		}
+		text = fmt.Sprintf("✅ %s upgraded!\n", name)
+	}
+
+	info := network.UpgradeInfo{
+		Gold:   player.Gold,
+		Cards:  levelStates(player.CardLevels, curves.Cards, cards),
+		Towers: levelStates(player.TowerLevels, curves.Towers, towers),
+	}
+	text += fmt.Sprintf("💰 Gold: %d", info.Gold)
+	for _, s := range append(append([]network.LevelState{}, info.Cards...), info.Towers...) {
+		text += fmt.Sprintf("\n%s Lv.%d (EXP: %d)", s.Name, s.Level, s.EXP)
+		if s.Max {
+			text += " MAX"
+		} else {
+			text += fmt.Sprintf(" → Lv.%d: %d EXP, %d gold", s.Level+1, s.NextEXP, s.NextGold)
+		}
+	}
+	conn.Reply(pdu, network.MsgUpgradeInfo, text, info)
+	return true
+}
+
+// matchName tìm name trong names không phân biệt hoa thường và chuẩn hoá name theo tên tìm được.
+func matchName(names []string, name *string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, *name) {
+			*name = n
+			return true
+		}
+	}
+	return false
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
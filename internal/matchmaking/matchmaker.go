@@ -0,0 +1,266 @@
+// Package matchmaking ghép cặp người chơi đang chờ theo từng chế độ chơi.
+// Mỗi chế độ có một hàng chờ FIFO; người chờ lâu nhất được ghép trước với
+// đối thủ có rating nằm trong cửa sổ nới rộng dần theo thời gian chờ.
+package matchmaking
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+	"net-centric-clash-royale/internal/utils"
+)
+
+const (
+	// WindowBase là chênh lệch rating tối đa khi vừa vào hàng chờ.
+	WindowBase = 100
+	// WindowGrowth là số điểm cửa sổ rating nới thêm mỗi giây chờ.
+	WindowGrowth = 25
+	// PairInterval là chu kỳ ghép lại các hàng chờ khi cửa sổ rating đã rộng hơn.
+	PairInterval = time.Second
+	// NotifyInterval là chu kỳ gửi "still searching" cho người đang chờ.
+	NotifyInterval = 5 * time.Second
+)
+
+// ErrAlreadyQueued được trả về khi người chơi đã có ticket trong một hàng chờ.
+var ErrAlreadyQueued = errors.New("already searching for a match")
+
+type ticketState int
+
+const (
+	stateQueued ticketState = iota
+	stateMatched
+	stateCancelled
+)
+
+// Ticket là một người chơi trong hàng chờ.
+type Ticket struct {
+	Player *models.Player
+	Conn   *network.Conn
+	Mode   models.GameMode
+	Rating int
+	Joined time.Time
+
+	// Matched nhận đối thủ khi ticket được ghép cặp.
+	Matched chan *Ticket
+	// Notify báo mỗi NotifyInterval rằng ticket vẫn đang chờ; goroutine của
+	// ticket tự gửi trạng thái cho client nên không chen vào sau lúc ghép cặp.
+	Notify chan struct{}
+	// released được đóng bằng Release khi goroutine của ticket đã trả lại Conn.
+	released chan struct{}
+	state    ticketState // bảo vệ bởi Matchmaker.mu
+}
+
+// Release báo cho matchmaker rằng goroutine chờ đã ngừng đọc Conn; trận đấu
+// chỉ bắt đầu khi cả hai ticket đã Release.
+func (t *Ticket) Release() {
+	close(t.released)
+}
+
+// Status là vị trí hiện tại của một ticket trong hàng chờ.
+type Status struct {
+	Position int // bắt đầu từ 1
+	Queued   int
+	Waited   time.Duration
+	Window   int
+}
+
+// Matchmaker giữ các hàng chờ và bắt đầu trận đấu cho mỗi cặp được ghép.
+type Matchmaker struct {
+	mu     sync.Mutex
+	queues map[models.GameMode][]*Ticket
+
+	// onMatch bắt đầu trận đấu; host là người đã chờ lâu hơn.
+	onMatch func(host, guest *Ticket)
+}
+
+// New tạo matchmaker; onMatch được gọi trong goroutine riêng sau khi cả hai
+// ticket đã Release.
+func New(onMatch func(host, guest *Ticket)) *Matchmaker {
+	return &Matchmaker{
+		queues:  make(map[models.GameMode][]*Ticket),
+		onMatch: onMatch,
+	}
+}
+
+// Window là chênh lệch rating chấp nhận được sau khi đã chờ waited.
+func Window(waited time.Duration) int {
+	return WindowBase + int(waited.Seconds())*WindowGrowth
+}
+
+// Enqueue đưa người chơi vào cuối hàng chờ của mode và ghép ngay nếu đã có
+// đối thủ phù hợp. Mỗi username chỉ được chờ ở một hàng chờ tại một thời điểm.
+func (m *Matchmaker) Enqueue(player *models.Player, conn *network.Conn, mode models.GameMode) (*Ticket, error) {
+	t := &Ticket{
+		Player:   player,
+		Conn:     conn,
+		Mode:     mode,
+		Rating:   utils.PlayerRating(player),
+		Joined:   time.Now(),
+		Matched:  make(chan *Ticket, 1),
+		Notify:   make(chan struct{}, 1),
+		released: make(chan struct{}),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.queued(player.Username) {
+		return nil, ErrAlreadyQueued
+	}
+	m.queues[mode] = append(m.queues[mode], t)
+	m.pair(mode, time.Now())
+	return t, nil
+}
+
+// Cancel bỏ t khỏi hàng chờ. Trả về false nếu t đã được ghép cặp; khi đó
+// đối thủ sẽ được gửi tới t.Matched.
+func (m *Matchmaker) Cancel(t *Ticket) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch t.state {
+	case stateMatched:
+		return false
+	case stateQueued:
+		m.remove(t)
+		t.state = stateCancelled
+	}
+	return true
+}
+
+// Status trả về vị trí của t trong hàng chờ; ok là false nếu t không còn chờ.
+func (m *Matchmaker) Status(t *Ticket) (status Status, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status(t, time.Now())
+}
+
+// Run ghép lại các hàng chờ mỗi PairInterval và gửi trạng thái tìm trận cho
+// người đang chờ mỗi NotifyInterval cho tới khi stop được đóng.
+func (m *Matchmaker) Run(stop <-chan struct{}) {
+	pairTicker := time.NewTicker(PairInterval)
+	defer pairTicker.Stop()
+	notifyTicker := time.NewTicker(NotifyInterval)
+	defer notifyTicker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-pairTicker.C:
+			m.mu.Lock()
+			for mode := range m.queues {
+				m.pair(mode, now)
+			}
+			m.mu.Unlock()
+		case <-notifyTicker.C:
+			m.notify()
+		}
+	}
+}
+
+// notify báo cho từng ticket còn trong hàng chờ qua Notify. Ticket chưa xử
+// lý lần báo trước thì bỏ qua lần này.
+func (m *Matchmaker) notify() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, queue := range m.queues {
+		for _, t := range queue {
+			select {
+			case t.Notify <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Describe trả về văn bản và dữ liệu PDU queue_status cho trạng thái của t.
+func Describe(t *Ticket, status Status) (string, network.QueueStatus) {
+	waited := int(status.Waited.Seconds())
+	text := fmt.Sprintf("🔎 Still searching... position %d of %d (%ds). Type 'cancel' to leave the queue.",
+		status.Position, status.Queued, waited)
+	return text, network.QueueStatus{
+		Mode:      t.Mode.String(),
+		Position:  status.Position,
+		Queued:    status.Queued,
+		WaitedSec: waited,
+		Rating:    t.Rating,
+		Window:    status.Window,
+	}
+}
+
+// pair ghép các ticket của mode theo thứ tự FIFO: mỗi ticket được ghép với
+// ticket cũ nhất phía sau có rating nằm trong cửa sổ của người đã chờ lâu
+// hơn trong hai người. Caller must hold m.mu.
+func (m *Matchmaker) pair(mode models.GameMode, now time.Time) {
+	queue := m.queues[mode]
+	for i := 0; i < len(queue); i++ {
+		host := queue[i]
+		window := Window(now.Sub(host.Joined))
+		for j := i + 1; j < len(queue); j++ {
+			guest := queue[j]
+			diff := guest.Rating - host.Rating
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > window {
+				continue
+			}
+			queue = append(queue[:j:j], queue[j+1:]...)
+			queue = append(queue[:i:i], queue[i+1:]...)
+			i--
+			m.match(host, guest)
+			break
+		}
+	}
+	m.queues[mode] = queue
+}
+
+// match đánh dấu hai ticket đã ghép cặp, báo cho cả hai và bắt đầu trận đấu
+// khi cả hai đã trả lại kết nối. Caller must hold m.mu.
+func (m *Matchmaker) match(host, guest *Ticket) {
+	host.state, guest.state = stateMatched, stateMatched
+	host.Matched <- guest
+	guest.Matched <- host
+	go func() {
+		<-host.released
+		<-guest.released
+		m.onMatch(host, guest)
+	}()
+}
+
+// queued cho biết username đã có ticket trong hàng chờ nào chưa. Caller must
+// hold m.mu.
+func (m *Matchmaker) queued(username string) bool {
+	for _, queue := range m.queues {
+		for _, t := range queue {
+			if t.Player.Username == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remove bỏ t khỏi hàng chờ của nó. Caller must hold m.mu.
+func (m *Matchmaker) remove(t *Ticket) {
+	queue := m.queues[t.Mode]
+	for i, other := range queue {
+		if other == t {
+			m.queues[t.Mode] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// status tính trạng thái của t. Caller must hold m.mu.
+func (m *Matchmaker) status(t *Ticket, now time.Time) (Status, bool) {
+	queue := m.queues[t.Mode]
+	for i, other := range queue {
+		if other == t {
+			waited := now.Sub(t.Joined)
+			return Status{Position: i + 1, Queued: len(queue), Waited: waited, Window: Window(waited)}, true
+		}
+	}
+	return Status{}, false
+}
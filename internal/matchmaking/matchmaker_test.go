@@ -0,0 +1,128 @@
+package matchmaking
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// enqueue đưa một người chơi có rating vào hàng chờ ModeUntimed.
+func enqueue(t *testing.T, m *Matchmaker, name string, rating int) *Ticket {
+	t.Helper()
+	ticket, err := m.Enqueue(&models.Player{Username: name, Rating: rating}, nil, models.ModeUntimed)
+	if err != nil {
+		t.Fatalf("Enqueue(%s) = %v", name, err)
+	}
+	return ticket
+}
+
+// opponent trả về đối thủ đã được ghép với ticket, hoặc "" nếu chưa ghép.
+func opponent(ticket *Ticket) string {
+	select {
+	case other := <-ticket.Matched:
+		return other.Player.Username
+	default:
+		return ""
+	}
+}
+
+func TestPairsOldestTicketFirst(t *testing.T) {
+	matches := make(chan [2]string, 1)
+	m := New(func(host, guest *Ticket) { matches <- [2]string{host.Player.Username, guest.Player.Username} })
+
+	alice := enqueue(t, m, "alice", 1000)
+	far := enqueue(t, m, "far", 1500)
+	bob := enqueue(t, m, "bob", 1050)
+	carol := enqueue(t, m, "carol", 1050)
+
+	if got := opponent(alice); got != "bob" {
+		t.Fatalf("alice matched with %q, want bob, the oldest ticket in alice's window", got)
+	}
+	if got := opponent(bob); got != "alice" {
+		t.Fatalf("bob matched with %q, want alice", got)
+	}
+	if got := opponent(carol); got != "" {
+		t.Fatalf("carol matched with %q, want carol to keep waiting", got)
+	}
+
+	alice.Release()
+	bob.Release()
+	if got := <-matches; got != [2]string{"alice", "bob"} {
+		t.Fatalf("onMatch(%s, %s), want alice to host bob", got[0], got[1])
+	}
+
+	status, ok := m.Status(far)
+	if !ok || status.Position != 1 || status.Queued != 2 {
+		t.Fatalf("Status(far) = %+v, %v; want position 1 of 2", status, ok)
+	}
+	if status, ok = m.Status(carol); !ok || status.Position != 2 {
+		t.Fatalf("Status(carol) = %+v, %v; want position 2", status, ok)
+	}
+	if _, ok := m.Status(alice); ok {
+		t.Fatal("Status reports a matched ticket as still queued")
+	}
+}
+
+func TestWindowWidensWhileWaiting(t *testing.T) {
+	m := New(func(host, guest *Ticket) {})
+	alice := enqueue(t, m, "alice", 1000)
+	bob := enqueue(t, m, "bob", 1300)
+
+	// Sau 7 giây cửa sổ là 275, chưa tới 300 điểm chênh lệch
+	m.mu.Lock()
+	m.pair(models.ModeUntimed, alice.Joined.Add(7*time.Second))
+	m.mu.Unlock()
+	if got := opponent(alice); got != "" {
+		t.Fatalf("matched with %q at window %d", got, Window(7*time.Second))
+	}
+
+	m.mu.Lock()
+	m.pair(models.ModeUntimed, alice.Joined.Add(8*time.Second))
+	m.mu.Unlock()
+	if got := opponent(alice); got != "bob" {
+		t.Fatalf("alice matched with %q at window %d, want bob", got, Window(8*time.Second))
+	}
+	if got := opponent(bob); got != "alice" {
+		t.Fatalf("bob matched with %q, want alice", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := New(func(host, guest *Ticket) {})
+	alice := enqueue(t, m, "alice", 1000)
+
+	if !m.Cancel(alice) {
+		t.Fatal("Cancel of a queued ticket = false")
+	}
+	if _, ok := m.Status(alice); ok {
+		t.Fatal("cancelled ticket is still queued")
+	}
+
+	// Huỷ rồi thì không còn được ghép và có thể vào lại hàng chờ
+	again := enqueue(t, m, "alice", 1000)
+	bob := enqueue(t, m, "bob", 1000)
+	if got := opponent(again); got != "bob" {
+		t.Fatalf("re-queued alice matched with %q, want bob", got)
+	}
+	if m.Cancel(bob) {
+		t.Fatal("Cancel of a matched ticket = true")
+	}
+	if got := opponent(bob); got != "alice" {
+		t.Fatalf("bob matched with %q after a failed Cancel, want alice", got)
+	}
+}
+
+func TestEnqueueRejectsQueuedPlayer(t *testing.T) {
+	m := New(func(host, guest *Ticket) {})
+	alice := enqueue(t, m, "alice", 1000)
+
+	_, err := m.Enqueue(&models.Player{Username: "alice", Rating: 1000}, nil, models.ModeRealtime)
+	if !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("second Enqueue = %v, want ErrAlreadyQueued", err)
+	}
+	if status, ok := m.Status(alice); !ok || status.Queued != 1 {
+		t.Fatalf("Status(alice) = %+v, %v; want the first ticket alone in the queue", status, ok)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Progress là cấp và EXP tích luỹ của một card hoặc một loại tower.
+type Progress struct {
+	Level int `json:"level"`
+	EXP   int `json:"exp"`
+}
+
+// LevelStep là một bậc trong đường cong cấp độ. Bậc thứ i (từ 0) ứng với
+// cấp i+1: Stat nhân vào HP/ATK/DEF, EXP và Gold là chi phí để lên cấp đó.
+type LevelStep struct {
+	Stat float64 `json:"stat"`
+	EXP  int     `json:"exp,omitempty"`
+	Gold int     `json:"gold,omitempty"`
+}
+
+// LevelCurves là đường cong cấp độ của card và tower, đọc từ data/levels.json.
+type LevelCurves struct {
+	Cards  []LevelStep `json:"cards"`
+	Towers []LevelStep `json:"towers"`
+}
+
+// Validate reports every step that is out of range in either curve.
+func (c LevelCurves) Validate() error {
+	var errs []error
+	for name, curve := range map[string][]LevelStep{"cards": c.Cards, "towers": c.Towers} {
+		if len(curve) == 0 {
+			errs = append(errs, fmt.Errorf("%s must have at least one level", name))
+		}
+		for i, step := range curve {
+			if step.Stat <= 0 {
+				errs = append(errs, fmt.Errorf("%s level %d: stat must be positive, got %g", name, i+1, step.Stat))
+			}
+			if i > 0 && step.Stat < curve[i-1].Stat {
+				errs = append(errs, fmt.Errorf("%s level %d: stat must not be lower than level %d", name, i+1, i))
+			}
+			if step.EXP < 0 || step.Gold < 0 {
+				errs = append(errs, fmt.Errorf("%s level %d: exp and gold must not be negative", name, i+1))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GameMode là luật chơi của một trận đấu.
+type GameMode int
+
+const (
+	ModeUntimed  GameMode = iota // theo lượt, không giới hạn thời gian
+	ModeTimed                    // theo lượt, giới hạn Ruleset.GameDurationSec
+	ModeRealtime                 // hai người chơi cùng lúc, server chạy tick mô phỏng
+)
+
+func (m GameMode) String() string {
+	switch m {
+	case ModeTimed:
+		return "timed"
+	case ModeRealtime:
+		return "realtime"
+	default:
+		return "untimed"
+	}
+}
+
+// Title là tên chế độ hiển thị cho người chơi, kèm thời lượng trận theo rules.
+func (m GameMode) Title(rules Ruleset) string {
+	switch m {
+	case ModeTimed:
+		return fmt.Sprintf("Timed Game (%s)", durationText(rules.GameDurationSec))
+	case ModeRealtime:
+		return fmt.Sprintf("Real-time Battle (%s)", durationText(rules.GameDurationSec))
+	default:
+		return "Untimed Game (play following turn)"
+	}
+}
+
+// durationText viết thời lượng theo phút nếu chia hết, ngược lại theo giây.
+func durationText(sec int) string {
+	switch {
+	case sec == 60:
+		return "1 minute"
+	case sec%60 == 0:
+		return fmt.Sprintf("%d minutes", sec/60)
+	}
+	return fmt.Sprintf("%d seconds", sec)
+}
+
+// Timed cho biết trận đấu có đồng hồ đếm ngược Ruleset.GameDuration hay không.
+func (m GameMode) Timed() bool {
+	return m == ModeTimed || m == ModeRealtime
+}
+
+// ParseModeName maps a mode name as returned by String back to a GameMode.
+func ParseModeName(name string) (GameMode, bool) {
+	for _, m := range []GameMode{ModeUntimed, ModeTimed, ModeRealtime} {
+		if strings.EqualFold(strings.TrimSpace(name), m.String()) {
+			return m, true
+		}
+	}
+	return ModeUntimed, false
+}
+
+// ParseModeChoice maps a menu answer ("1", "2", "3") to a GameMode.
+func ParseModeChoice(choice string) (GameMode, bool) {
+	switch strings.TrimSpace(choice) {
+	case "1":
+		return ModeTimed, true
+	case "2":
+		return ModeUntimed, true
+	case "3":
+		return ModeRealtime, true
+	}
+	return ModeUntimed, false
+}
@@ -1,14 +1,41 @@
 package models
 
+import (
+	"maps"
+	"slices"
+)
+
 type Player struct {
-	Username      string    `json:"username"`
-	Password      string    `json:"password"`
-	EXP           int       `json:"exp"`
-	Level         int       `json:"level"`
-	Mana          int       `json:"mana"`
-	Towers        []Tower   `json:"towers"`
-	Troops        []Troop   `json:"troops"`
-	GameModeTimed bool      `json:"-"` // Added for game mode selection, not persisted
-	WaitChannel   chan bool `json:"-"` // Channel for signaling match found (true) or timeout (false), not persisted
+	Username      string              `json:"username"`
+	Password      string              `json:"password,omitempty"` // Mật khẩu plaintext cũ, chỉ đọc để nâng cấp lên hash
+	PasswordHash  string              `json:"passwordHash,omitempty"`
+	EXP           int                 `json:"exp"`
+	Level         int                 `json:"level"`
+	Mana          int                 `json:"mana"`
+	Towers        []Tower             `json:"towers"`
+	Troops        []Troop             `json:"troops"`               // bài trên tay trong trận
+	Collection    map[string]int      `json:"collection,omitempty"` // số bản của mỗi card người chơi sở hữu
+	Deck          []string            `json:"deck,omitempty"`       // tên 8 card trong deck đã lưu
+	DrawPile      []Troop             `json:"-"`                    // hàng chờ rút bài trong trận, card đầu là card kế tiếp
+	Gold          int                 `json:"gold"`
+	Rating        int                 `json:"rating,omitempty"`      // Elo, 0 nghĩa là chưa có
+	CardLevels    map[string]Progress `json:"cardLevels,omitempty"`  // theo tên card
+	TowerLevels   map[string]Progress `json:"towerLevels,omitempty"` // theo loại tower
+	GameModeTimed bool                `json:"-"`                     // Added for game mode selection, not persisted
+	WaitChannel   chan bool           `json:"-"`                     // Channel for signaling match found (true) or timeout (false), not persisted
 	CritsLeft     int
 }
+
+// Clone trả về bản sao sâu của p: slice và map được sao chép, tham số ability
+// của troop vẫn dùng chung vì chỉ được đọc.
+func (p *Player) Clone() *Player {
+	c := *p
+	c.Towers = slices.Clone(p.Towers)
+	c.Troops = slices.Clone(p.Troops)
+	c.DrawPile = slices.Clone(p.DrawPile)
+	c.Deck = slices.Clone(p.Deck)
+	c.Collection = maps.Clone(p.Collection)
+	c.CardLevels = maps.Clone(p.CardLevels)
+	c.TowerLevels = maps.Clone(p.TowerLevels)
+	return &c
+}
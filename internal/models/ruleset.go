@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reward là phần thưởng EXP và gold cho một kết quả trận đấu.
+type Reward struct {
+	EXP  int `json:"exp"`
+	Gold int `json:"gold"`
+}
+
+// Rewards là phần thưởng theo từng cách kết thúc trận.
+type Rewards struct {
+	KingWin    Reward `json:"kingWin"`  // phá King Tower của đối thủ
+	KingLoss   Reward `json:"kingLoss"` // bị phá King Tower
+	TimeWin    Reward `json:"timeWin"`  // hết giờ, phá nhiều tower hơn
+	TimeLoss   Reward `json:"timeLoss"`
+	Draw       Reward `json:"draw"`
+	ForfeitWin Reward `json:"forfeitWin"` // đối thủ không kết nối lại kịp
+}
+
+// Ruleset gom các hằng số luật chơi của một trận, đọc từ data/rules.json.
+type Ruleset struct {
+	GameDurationSec       int      `json:"gameDurationSec"`
+	MatchmakingTimeoutSec int      `json:"matchmakingTimeoutSec"`
+	ReconnectGraceSec     int      `json:"reconnectGraceSec"` // thời gian chờ người chơi mất kết nối quay lại
+	StartMana             int      `json:"startMana"`
+	MaxMana               int      `json:"maxMana"`
+	ManaRegenRate         int      `json:"manaRegenRate"` // mana hồi mỗi giây
+	MaxCritsPerGame       int      `json:"maxCritsPerGame"`
+	CritMultiplier        float64  `json:"critMultiplier"`
+	HealAmount            int      `json:"healAmount"` // mặc định cho ability heal không khai báo "amount"
+	MaxHealHP             int      `json:"maxHealHp"`  // mặc định cho ability heal không khai báo "max_hp"
+	HandSize              int      `json:"handSize"`
+	DeckSize              int      `json:"deckSize"`
+	MaxCombatRounds       int      `json:"maxCombatRounds"`
+	AllowedCards          []string `json:"allowedCards,omitempty"` // rỗng = mọi card
+	SpectatorHands        bool     `json:"spectatorHands"`         // người xem thấy bài trên tay
+	Rewards               Rewards  `json:"rewards"`
+
+	// Levels được nạp từ data/levels.json lúc khởi động, không nằm trong rules.json
+	Levels LevelCurves `json:"-"`
+	// Troops là danh mục troop từ data/troop.json, nạp lúc khởi động; engine
+	// tìm troop xuất hiện khi troop chết (ability spawn) trong đây
+	Troops []Troop `json:"-"`
+}
+
+// DefaultRuleset returns the standard rules.
+func DefaultRuleset() Ruleset {
+	return Ruleset{
+		GameDurationSec:       180,
+		MatchmakingTimeoutSec: 30,
+		ReconnectGraceSec:     30,
+		StartMana:             10,
+		MaxMana:               10,
+		ManaRegenRate:         1,
+		MaxCritsPerGame:       5,
+		CritMultiplier:        1.2,
+		HealAmount:            200,
+		MaxHealHP:             1000,
+		HandSize:              4,
+		DeckSize:              8,
+		MaxCombatRounds:       10,
+		Rewards: Rewards{
+			KingWin:    Reward{EXP: 30, Gold: 100},
+			KingLoss:   Reward{EXP: 10, Gold: 20},
+			TimeWin:    Reward{EXP: 20, Gold: 100},
+			TimeLoss:   Reward{EXP: 5, Gold: 20},
+			Draw:       Reward{EXP: 10, Gold: 40},
+			ForfeitWin: Reward{EXP: 30, Gold: 100},
+		},
+	}
+}
+
+// GameDuration is the length of a timed or real-time match.
+func (r Ruleset) GameDuration() time.Duration {
+	return time.Duration(r.GameDurationSec) * time.Second
+}
+
+// MatchmakingTimeout is how long a player waits in a queue for an opponent.
+func (r Ruleset) MatchmakingTimeout() time.Duration {
+	return time.Duration(r.MatchmakingTimeoutSec) * time.Second
+}
+
+// ReconnectGrace is how long a match waits for a disconnected player before
+// they forfeit.
+func (r Ruleset) ReconnectGrace() time.Duration {
+	return time.Duration(r.ReconnectGraceSec) * time.Second
+}
+
+// Validate reports every rule that is out of range.
+func (r Ruleset) Validate() error {
+	var errs []error
+	positive := func(name string, v int) {
+		if v <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %d", name, v))
+		}
+	}
+	positive("gameDurationSec", r.GameDurationSec)
+	positive("matchmakingTimeoutSec", r.MatchmakingTimeoutSec)
+	positive("reconnectGraceSec", r.ReconnectGraceSec)
+	positive("maxMana", r.MaxMana)
+	positive("handSize", r.HandSize)
+	positive("deckSize", r.DeckSize)
+	positive("maxCombatRounds", r.MaxCombatRounds)
+	if r.StartMana < 0 || r.StartMana > r.MaxMana {
+		errs = append(errs, fmt.Errorf("startMana must be between 0 and maxMana (%d), got %d", r.MaxMana, r.StartMana))
+	}
+	if r.ManaRegenRate < 0 {
+		errs = append(errs, fmt.Errorf("manaRegenRate must not be negative, got %d", r.ManaRegenRate))
+	}
+	if r.MaxCritsPerGame < 0 {
+		errs = append(errs, fmt.Errorf("maxCritsPerGame must not be negative, got %d", r.MaxCritsPerGame))
+	}
+	if r.CritMultiplier < 1 {
+		errs = append(errs, fmt.Errorf("critMultiplier must be at least 1, got %g", r.CritMultiplier))
+	}
+	if r.HealAmount < 0 || r.MaxHealHP < 0 {
+		errs = append(errs, fmt.Errorf("healAmount and maxHealHp must not be negative"))
+	}
+	if r.HandSize > r.DeckSize {
+		errs = append(errs, fmt.Errorf("handSize (%d) must not exceed deckSize (%d)", r.HandSize, r.DeckSize))
+	}
+	return errors.Join(errs...)
+}
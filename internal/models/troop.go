@@ -1,11 +1,13 @@
 package models
 
 type Troop struct {
-	Name    string `json:"name"`
-	HP      int    `json:"hp"`
-	ATK     int    `json:"atk"`
-	DEF     int    `json:"def"`
-	Mana    int    `json:"mana"`
-	EXP     int    `json:"exp"`
-	Special string `json:"special,omitempty"`
+	Name    string                 `json:"name"`
+	HP      int                    `json:"hp"`
+	ATK     int                    `json:"atk"`
+	DEF     int                    `json:"def"`
+	Mana    int                    `json:"mana"`
+	EXP     int                    `json:"exp"`
+	Speed   float64                `json:"speed,omitempty"`   // số ô di chuyển mỗi giây trên arena
+	Special string                 `json:"special,omitempty"` // tên ability, nhiều ability cách nhau bởi dấu phẩy
+	Params  map[string]interface{} `json:"params,omitempty"`  // tham số của ability
 }
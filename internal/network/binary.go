@@ -0,0 +1,293 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Dữ liệu có kiểu trong frame của BinaryCodec được mã hoá theo thứ tự field
+// của kiểu đã đăng ký trong messageTypes, không qua JSON:
+//
+//	bool = 1 byte | int = varint | uint = uvarint | float = 8 byte IEEE 754 |
+//	string = uvarint(độ dài) + byte | slice, map = uvarint(số phần tử + 1),
+//	0 là nil, rồi các phần tử (map theo key tăng dần) | pointer = 1 byte
+//	(0 là nil) + giá trị | time.Time = string(MarshalBinary)
+//
+// Field không export và field có tag json:"-" bị bỏ qua như trong JSON.
+
+// Byte đầu của phần dữ liệu trong frame binary.
+const (
+	dataNone   byte = 0
+	dataJSON   byte = 1 // string chứa JSON, cho dữ liệu chưa đăng ký kiểu
+	dataFields byte = 2 // field của kiểu trong messageTypes
+)
+
+var (
+	errUnsupportedType = errors.New("type has no binary encoding")
+	timeType           = reflect.TypeFor[time.Time]()
+)
+
+// appendData ghi phần dữ liệu của pdu vào buf.
+func appendData(buf []byte, pdu PDU) ([]byte, error) {
+	if pdu.Msg == nil {
+		if len(pdu.Data) == 0 {
+			return append(buf, dataNone), nil
+		}
+		return appendString(append(buf, dataJSON), string(pdu.Data)), nil
+	}
+	v := reflect.ValueOf(pdu.Msg)
+	if t, ok := messageTypes[pdu.Type]; ok && v.Type() == t {
+		return appendValue(append(buf, dataFields), v)
+	}
+	raw, err := encodeMsg(pdu)
+	if err != nil {
+		return nil, err
+	}
+	return appendString(append(buf, dataJSON), string(raw)), nil
+}
+
+// readData đọc phần dữ liệu cuối frame vào pdu.
+func readData(body []byte, pdu *PDU) error {
+	if len(body) == 0 {
+		return errMalformedFrame
+	}
+	switch body[0] {
+	case dataNone:
+		if len(body) != 1 {
+			return errMalformedFrame
+		}
+	case dataJSON:
+		data, rest, ok := readString(body[1:])
+		if !ok || len(rest) != 0 {
+			return errMalformedFrame
+		}
+		pdu.Data = []byte(data)
+	case dataFields:
+		t, ok := messageTypes[pdu.Type]
+		if !ok {
+			return fmt.Errorf("%w: no data type for %q", errMalformedFrame, pdu.Type)
+		}
+		v := reflect.New(t).Elem()
+		rest, err := readValue(body[1:], v)
+		if err != nil {
+			return err
+		}
+		if len(rest) != 0 {
+			return errMalformedFrame
+		}
+		pdu.Msg = v.Interface()
+	default:
+		return fmt.Errorf("%w: unknown data kind %d", errMalformedFrame, body[0])
+	}
+	return nil
+}
+
+// binaryFields trả về vị trí các field của struct t được mã hoá.
+func binaryFields(t reflect.Type) []int {
+	var fields []int
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.IsExported() && f.Tag.Get("json") != "-" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	var err error
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(buf, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+		for i := range v.Len() {
+			if buf, err = appendValue(buf, v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedType, v.Type())
+		}
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		buf = binary.AppendUvarint(buf, uint64(len(keys))+1)
+		for _, k := range keys {
+			buf = appendString(buf, k.String())
+			if buf, err = appendValue(buf, v.MapIndex(k)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendValue(append(buf, 1), v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			raw, err := v.Interface().(time.Time).MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			return appendString(buf, string(raw)), nil
+		}
+		for _, i := range binaryFields(v.Type()) {
+			if buf, err = appendValue(buf, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedType, v.Type())
+}
+
+// readValue đọc một giá trị kiểu v.Type() từ đầu buf vào v và trả về phần còn lại.
+func readValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(buf) == 0 || buf[0] > 1 {
+			return nil, errMalformedFrame
+		}
+		v.SetBool(buf[0] == 1)
+		return buf[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, n := binary.Varint(buf)
+		if n <= 0 || v.OverflowInt(x) {
+			return nil, errMalformedFrame
+		}
+		v.SetInt(x)
+		return buf[n:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, n := binary.Uvarint(buf)
+		if n <= 0 || v.OverflowUint(x) {
+			return nil, errMalformedFrame
+		}
+		v.SetUint(x)
+		return buf[n:], nil
+	case reflect.Float32, reflect.Float64:
+		if len(buf) < 8 {
+			return nil, errMalformedFrame
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+		return buf[8:], nil
+	case reflect.String:
+		s, rest, ok := readString(buf)
+		if !ok {
+			return nil, errMalformedFrame
+		}
+		v.SetString(s)
+		return rest, nil
+	case reflect.Slice:
+		count, rest, err := readCount(buf)
+		if err != nil || count < 0 {
+			return rest, err
+		}
+		s := reflect.MakeSlice(v.Type(), count, count)
+		for i := range count {
+			if rest, err = readValue(rest, s.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		v.Set(s)
+		return rest, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedType, v.Type())
+		}
+		count, rest, err := readCount(buf)
+		if err != nil || count < 0 {
+			return rest, err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), count)
+		for range count {
+			key, after, ok := readString(rest)
+			if !ok {
+				return nil, errMalformedFrame
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if rest, err = readValue(after, elem); err != nil {
+				return nil, err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+		return rest, nil
+	case reflect.Pointer:
+		if len(buf) == 0 || buf[0] > 1 {
+			return nil, errMalformedFrame
+		}
+		if buf[0] == 0 {
+			return buf[1:], nil
+		}
+		p := reflect.New(v.Type().Elem())
+		rest, err := readValue(buf[1:], p.Elem())
+		if err != nil {
+			return nil, err
+		}
+		v.Set(p)
+		return rest, nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			raw, rest, ok := readString(buf)
+			if !ok {
+				return nil, errMalformedFrame
+			}
+			var t time.Time
+			if err := t.UnmarshalBinary([]byte(raw)); err != nil {
+				return nil, fmt.Errorf("%w: %v", errMalformedFrame, err)
+			}
+			v.Set(reflect.ValueOf(t))
+			return rest, nil
+		}
+		var err error
+		for _, i := range binaryFields(v.Type()) {
+			if buf, err = readValue(buf, v.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errUnsupportedType, v.Type())
+}
+
+// readCount đọc số phần tử của slice hoặc map; -1 nghĩa là nil. Mỗi phần tử
+// chiếm ít nhất một byte nên số phần tử lớn hơn phần còn lại là frame hỏng.
+func readCount(buf []byte) (int, []byte, error) {
+	n, k := binary.Uvarint(buf)
+	if k <= 0 {
+		return 0, nil, errMalformedFrame
+	}
+	rest := buf[k:]
+	if n == 0 {
+		return -1, rest, nil
+	}
+	if n-1 > uint64(len(rest)) {
+		return 0, nil, errMalformedFrame
+	}
+	return int(n - 1), rest, nil
+}
@@ -8,20 +8,54 @@ import (
 	"strings"
 )
 
+const (
+	clientName    = "clash-cli"
+	clientVersion = "1.1.0"
+
+	// sessionFile lưu session token để lần sau không phải đăng nhập lại.
+	sessionFile = ".clash_session"
+)
+
 func StartTCPClient(address string) {
-	conn, err := net.Dial("tcp", address)
+	raw, err := net.Dial("tcp", address)
 	if err != nil {
 		fmt.Println("❌ Error connecting:", err)
 		return
 	}
+	conn := NewConn(raw)
 	defer conn.Close()
 
+	welcome, err := conn.ClientHandshake(Hello{
+		ClientName:    clientName,
+		ClientVersion: clientVersion,
+		Versions:      SupportedVersions,
+		Codecs:        SupportedCodecs(),
+		Features:      []string{FeatureTimed, FeatureRealtime, FeatureReconnect, FeatureSpectate},
+		SessionToken:  loadSessionToken(),
+	})
+	if err != nil {
+		fmt.Println("❌ Handshake failed:", err)
+		return
+	}
+	fmt.Printf("🤝 Connected to %s (protocol v%d, %s codec)\n", welcome.ServerName, welcome.Version, welcome.Codec)
+
 	// Start goroutine to receive and print messages from server
 	go func() {
-		scanner := bufio.NewScanner(conn)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Println("📥", line)
+		for {
+			pdu, err := conn.ReadPDU()
+			if err != nil {
+				fmt.Println("❌ Disconnected:", err)
+				os.Exit(0)
+			}
+			if pdu.Type == MsgSessionToken {
+				saveSessionToken(pdu)
+				continue
+			}
+			// PDU chỉ có data (không có text) dành cho client đồ hoạ
+			if pdu.Payload == "" {
+				continue
+			}
+			fmt.Println("📥", pdu.Payload)
 		}
 	}()
 
@@ -32,14 +66,27 @@ func StartTCPClient(address string) {
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
 
-		pdu := PDU{
-			Type:    "input",
-			Payload: input,
+		if err := conn.SendPDU("input", input); err != nil {
+			fmt.Println("❌ Failed to send:", err)
+			return
 		}
+	}
+}
 
-		data, err := EncodePDU(pdu)
-		if err == nil {
-			conn.Write(append(data, '\n'))
-		}
+func loadSessionToken() string {
+	data, err := os.ReadFile(sessionFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func saveSessionToken(pdu PDU) {
+	var token SessionToken
+	if err := pdu.DecodeData(&token); err != nil {
+		return
+	}
+	if err := os.WriteFile(sessionFile, []byte(token.Token), 0600); err != nil {
+		fmt.Println("⚠️ Could not save session token:", err)
 	}
 }
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codec encodes PDUs into frames on the wire and reads them back.
+type Codec interface {
+	// Name là tên dùng khi thoả thuận codec trong handshake.
+	Name() string
+	// Encode trả về một frame hoàn chỉnh (kể cả ký tự phân cách / length prefix).
+	Encode(pdu PDU) ([]byte, error)
+	// Split trả về độ dài frame đầu tiên trong buf, hoặc 0 nếu buf chưa chứa
+	// đủ một frame. Frame dài hơn maxSize byte (0 = không giới hạn) là lỗi.
+	Split(buf []byte, maxSize int) (int, error)
+	// Decode giải mã một frame do Split tách ra.
+	Decode(frame []byte) (PDU, error)
+}
+
+const (
+	CodecJSON   = "json"
+	CodecBinary = "binary"
+)
+
+// codecs liệt kê các codec server hỗ trợ, theo thứ tự ưu tiên.
+var codecs = []Codec{BinaryCodec{}, JSONCodec{}}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, bool) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// SupportedCodecs returns the names of all codecs, most preferred first.
+func SupportedCodecs() []string {
+	names := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// NegotiateCodec picks the most preferred server codec the peer also offers.
+// A peer that offers nothing gets JSON.
+func NegotiateCodec(offered []string) (Codec, error) {
+	if len(offered) == 0 {
+		return JSONCodec{}, nil
+	}
+	for _, c := range codecs {
+		for _, name := range offered {
+			if strings.EqualFold(name, c.Name()) {
+				return c, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no common codec in %v", offered)
+}
+
+// JSONCodec là codec mặc định: mỗi PDU là một dòng JSON.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return CodecJSON }
+
+func (JSONCodec) Encode(pdu PDU) ([]byte, error) {
+	data, err := EncodePDU(pdu)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (JSONCodec) Split(buf []byte, maxSize int) (int, error) {
+	end := bytes.IndexByte(buf, '\n')
+	size := end + 1
+	if end < 0 {
+		size = len(buf)
+	}
+	if maxSize > 0 && size > maxSize {
+		return 0, ErrFrameTooLarge
+	}
+	if end < 0 {
+		return 0, nil
+	}
+	return size, nil
+}
+
+func (JSONCodec) Decode(frame []byte) (PDU, error) {
+	return DecodePDU(bytes.TrimSpace(frame))
+}
+
+// BinaryCodec is a compact length-prefixed codec:
+//
+//	uvarint(frame length) | type byte | uvarint(version) | uvarint(seq) |
+//	string(rid) | string(payload) | data
+//
+// where string = uvarint(length) followed by the bytes. Type byte 0 means the
+// type name follows as a string. data starts with a kind byte: nothing, a
+// JSON string, or the fields of the type registered for the PDU type (see
+// binary.go).
+type BinaryCodec struct{}
+
+// binaryTypes gán một byte cho các loại PDU hay dùng. Không được đổi thứ tự.
+var binaryTypes = []string{
+	"", "input", "menu", "info", "error", "success", "select", "result", "event", "broadcast", "status",
+	MsgAttackRequest, MsgAttackResult, MsgHealResult, MsgTowerDestroyed, MsgTurnStarted,
+	MsgMatchFound, MsgStatusSnapshot, MsgGameOver, MsgSessionToken, MsgLogout,
+	MsgPlayerDisconnected, MsgPlayerReconnected, MsgStateResync,
+	MsgDeploy, MsgStateDelta,
+	MsgDeck, MsgSaveDeck, MsgUpgrade, MsgUpgradeInfo,
+	MsgCreatePrivate, MsgPrivateCreated, MsgJoinPrivate, MsgQueueStatus, MsgCancelQueue,
+	MsgListMatches, MsgMatchList, MsgSpectate, MsgSpectateState, MsgStopSpectating,
+	MsgReplay, MsgReplayList, MsgReplayStart, MsgReplayControl, MsgReplayEnd,
+	MsgPlayBot, MsgMatchHistory, MsgHello, MsgWelcome,
+}
+
+var errMalformedFrame = errors.New("malformed binary frame")
+
+func (BinaryCodec) Name() string { return CodecBinary }
+
+func (BinaryCodec) Encode(pdu PDU) ([]byte, error) {
+	body := make([]byte, 0, 32+len(pdu.Payload)+len(pdu.Data))
+
+	typeByte := byte(0)
+	for i, t := range binaryTypes {
+		if i > 0 && t == pdu.Type {
+			typeByte = byte(i)
+			break
+		}
+	}
+	body = append(body, typeByte)
+	if typeByte == 0 {
+		body = appendString(body, pdu.Type)
+	}
+	body = binary.AppendUvarint(body, uint64(pdu.Version))
+	body = binary.AppendUvarint(body, pdu.Seq)
+	body = appendString(body, pdu.RequestID)
+	body = appendString(body, pdu.Payload)
+	body, err := appendData(body, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := binary.AppendUvarint(make([]byte, 0, len(body)+binary.MaxVarintLen32), uint64(len(body)))
+	return append(frame, body...), nil
+}
+
+func (BinaryCodec) Split(buf []byte, maxSize int) (int, error) {
+	size, n := binary.Uvarint(buf)
+	if n == 0 {
+		// Length prefix chưa đến đủ
+		if len(buf) >= binary.MaxVarintLen64 {
+			return 0, errMalformedFrame
+		}
+		return 0, nil
+	}
+	if n < 0 {
+		return 0, errMalformedFrame
+	}
+	if maxSize > 0 && size > uint64(maxSize) {
+		return 0, ErrFrameTooLarge
+	}
+	if uint64(len(buf)-n) < size {
+		return 0, nil
+	}
+	return n + int(size), nil
+}
+
+func (BinaryCodec) Decode(frame []byte) (PDU, error) {
+	size, n := binary.Uvarint(frame)
+	if n <= 0 || uint64(len(frame)-n) != size {
+		return PDU{}, errMalformedFrame
+	}
+	return decodeBinaryBody(frame[n:])
+}
+
+func decodeBinaryBody(body []byte) (PDU, error) {
+	var pdu PDU
+	if len(body) == 0 {
+		return PDU{}, errMalformedFrame
+	}
+	typeByte := body[0]
+	body = body[1:]
+
+	var ok bool
+	if typeByte == 0 {
+		if pdu.Type, body, ok = readString(body); !ok {
+			return PDU{}, errMalformedFrame
+		}
+	} else if int(typeByte) < len(binaryTypes) {
+		pdu.Type = binaryTypes[typeByte]
+	} else {
+		return PDU{}, fmt.Errorf("%w: unknown type byte %d", errMalformedFrame, typeByte)
+	}
+
+	version, n := binary.Uvarint(body)
+	if n <= 0 {
+		return PDU{}, errMalformedFrame
+	}
+	pdu.Version = int(version)
+	body = body[n:]
+
+	if pdu.Seq, n = binary.Uvarint(body); n <= 0 {
+		return PDU{}, errMalformedFrame
+	}
+	body = body[n:]
+
+	if pdu.RequestID, body, ok = readString(body); !ok {
+		return PDU{}, errMalformedFrame
+	}
+	if pdu.Payload, body, ok = readString(body); !ok {
+		return PDU{}, errMalformedFrame
+	}
+	if err := readData(body, &pdu); err != nil {
+		return PDU{}, err
+	}
+	return pdu, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(buf []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < size {
+		return "", nil, false
+	}
+	end := n + int(size)
+	return string(buf[n:end]), buf[end:], true
+}
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	pdus := []PDU{
+		{Type: "input", Payload: "1"},
+		{Version: ProtocolVersion, Seq: 42, RequestID: "r-9", Type: MsgAttackRequest, Data: []byte(`{"troop":"Knight","tower":1,"useCrit":true}`)},
+		{Version: ProtocolVersion, Seq: 300, Type: "custom_type", Payload: "xin chào 🏰\nnhiều dòng"},
+		{Version: ProtocolVersion, Type: MsgStateDelta, Payload: strings.Repeat("x", 1000)},
+	}
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		for _, want := range pdus {
+			frame, err := codec.Encode(want)
+			if err != nil {
+				t.Fatalf("%s: Encode(%+v): %v", codec.Name(), want, err)
+			}
+			n, err := codec.Split(frame, 0)
+			if err != nil || n != len(frame) {
+				t.Fatalf("%s: Split = %d, %v; want %d", codec.Name(), n, err, len(frame))
+			}
+			got, err := codec.Decode(frame[:n])
+			if err != nil {
+				t.Fatalf("%s: Decode: %v", codec.Name(), err)
+			}
+			if got.Version != want.Version || got.Seq != want.Seq || got.RequestID != want.RequestID ||
+				got.Type != want.Type || got.Payload != want.Payload || string(got.Data) != string(want.Data) {
+				t.Fatalf("%s: round trip = %+v, want %+v", codec.Name(), got, want)
+			}
+		}
+	}
+}
+
+func TestCodecSplitIncompleteFrame(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		frame, err := codec.Encode(PDU{Version: ProtocolVersion, Type: "info", Payload: strings.Repeat("a", 200)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cut := range []int{0, 1, len(frame) / 2, len(frame) - 1} {
+			if n, err := codec.Split(frame[:cut], 0); n != 0 || err != nil {
+				t.Fatalf("%s: Split(%d of %d bytes) = %d, %v; want 0, nil", codec.Name(), cut, len(frame), n, err)
+			}
+		}
+	}
+}
+
+func TestBinaryCodecMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"truncated uvarint", []byte{0x80}},
+		{"length mismatch", []byte{0x05, 0x01}},
+		{"empty body", []byte{0x00}},
+		{"unknown type byte", []byte{0x03, 0xff, 0x01, 0x00}},
+		{"truncated string", []byte{0x04, 0x01, 0x01, 0x00, 0x09}},
+		{"missing data kind", []byte{0x05, 0x01, 0x01, 0x00, 0x00, 0x00}},
+		{"unknown data kind", []byte{0x06, 0x01, 0x01, 0x00, 0x00, 0x00, 0x07}},
+		{"fields for an untyped message", []byte{0x06, 0x01, 0x01, 0x00, 0x00, 0x00, 0x02}},
+		{"truncated fields", []byte{0x07, 0x0b, 0x01, 0x00, 0x00, 0x00, 0x02, 0x06}},
+		{"huge slice count", []byte{0x08, 0x1a, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x7f}},
+	}
+	for _, tt := range tests {
+		if _, err := (BinaryCodec{}).Decode(tt.frame); !errors.Is(err, errMalformedFrame) {
+			t.Errorf("%s: Decode = %v, want errMalformedFrame", tt.name, err)
+		}
+	}
+
+	// Length prefix dài hơn uvarint 64-bit
+	overflow := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+	if _, err := (BinaryCodec{}).Split(overflow, 0); !errors.Is(err, errMalformedFrame) {
+		t.Errorf("Split(overflowing uvarint) = %v, want errMalformedFrame", err)
+	}
+}
+
+func TestCodecMaxFrameSize(t *testing.T) {
+	const max = 64
+
+	// Binary: length prefix lớn bị từ chối trước khi body đến
+	prefix := binary.AppendUvarint(nil, max+1)
+	if _, err := (BinaryCodec{}).Split(prefix, max); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("binary Split(size %d) = %v, want ErrFrameTooLarge", max+1, err)
+	}
+
+	// JSON: dòng chưa kết thúc nhưng đã vượt giới hạn
+	if _, err := (JSONCodec{}).Split([]byte(strings.Repeat("x", max+1)), max); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("json Split(unterminated %d bytes) = %v, want ErrFrameTooLarge", max+1, err)
+	}
+	if _, err := (JSONCodec{}).Split([]byte(strings.Repeat("x", max)+"\n"), max); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("json Split(%d bytes + newline) = %v, want ErrFrameTooLarge", max, err)
+	}
+
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		frame, err := codec.Encode(PDU{Type: "input", Payload: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := codec.Split(frame, len(frame)); err != nil || n != len(frame) {
+			t.Errorf("%s: Split(frame at limit) = %d, %v", codec.Name(), n, err)
+		}
+	}
+}
+
+func TestBinaryCodecTypedMessages(t *testing.T) {
+	mana := 5
+	msgs := []PDU{
+		{Type: MsgAttackRequest, Msg: AttackRequest{Troop: "Knight", Tower: 1, UseCrit: true}},
+		{Type: MsgStateDelta, Msg: StateDelta{
+			Tick:  7,
+			Mana:  -1,
+			Units: []UnitState{{ID: 3, Owner: "alice", Troop: "Pawn", Lane: "left", Pos: 12.75, State: "walking", HP: 40}},
+		}},
+		{Type: MsgGameOver, Msg: GameOver{Winner: "alice", Reason: "time_up", Ratings: map[string]int{"alice": 1016, "bob": 984}}},
+		{Type: MsgCreatePrivate, Msg: CreatePrivate{Mode: "timed", StartMana: &mana, Cards: []string{"Pawn", "Queen"}}},
+		{Type: MsgSessionToken, Msg: SessionToken{Token: "t", ExpiresAt: time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)}},
+		{Type: MsgReplayList, Msg: ReplayList{Replays: []ReplayInfo{{ID: "r1", Player1: "alice", Player2: "bob", Mode: "untimed"}}}},
+	}
+	for _, want := range msgs {
+		want.Version = ProtocolVersion
+		frame, err := BinaryCodec{}.Encode(want)
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", want.Type, err)
+		}
+		if bytes.Contains(frame, []byte(`":`)) {
+			t.Errorf("%s: binary frame still carries JSON: %q", want.Type, frame)
+		}
+		got, err := BinaryCodec{}.Decode(frame)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", want.Type, err)
+		}
+		if !reflect.DeepEqual(got.Msg, want.Msg) {
+			t.Errorf("%s: Msg = %#v, want %#v", want.Type, got.Msg, want.Msg)
+		}
+
+		// DecodeData cho cùng kết quả với JSON codec
+		target := reflect.New(reflect.TypeOf(want.Msg))
+		if err := got.DecodeData(target.Interface()); err != nil {
+			t.Fatalf("%s: DecodeData: %v", want.Type, err)
+		}
+		jsonFrame, err := JSONCodec{}.Encode(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromJSON, err := JSONCodec{}.Decode(jsonFrame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		viaJSON := reflect.New(reflect.TypeOf(want.Msg))
+		if err := fromJSON.DecodeData(viaJSON.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(target.Elem().Interface(), viaJSON.Elem().Interface()) {
+			t.Errorf("%s: binary %#v, json %#v", want.Type, target.Elem().Interface(), viaJSON.Elem().Interface())
+		}
+	}
+}
+
+func TestBinaryCodecUnregisteredData(t *testing.T) {
+	// Kiểu khác với kiểu đã đăng ký được gửi dưới dạng JSON
+	want := PDU{Version: ProtocolVersion, Type: MsgReplayStart, Msg: map[string]interface{}{"id": "r1", "seq": 2.0}}
+	frame, err := BinaryCodec{}.Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := BinaryCodec{}.Decode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := got.DecodeData(&data); err != nil || !reflect.DeepEqual(data, want.Msg) {
+		t.Fatalf("DecodeData = %v, %v; want %v", data, err, want.Msg)
+	}
+}
+
+func TestBinaryTypesRegistered(t *testing.T) {
+	for pduType, typ := range messageTypes {
+		if !slices.Contains(binaryTypes, pduType) {
+			t.Errorf("%s has a data type but no type byte", pduType)
+		}
+		zero := reflect.Zero(typ).Interface()
+		frame, err := BinaryCodec{}.Encode(PDU{Type: pduType, Msg: zero})
+		if err != nil {
+			t.Errorf("%s: Encode(zero %s): %v", pduType, typ, err)
+			continue
+		}
+		got, err := BinaryCodec{}.Decode(frame)
+		if err != nil || !reflect.DeepEqual(got.Msg, zero) {
+			t.Errorf("%s: Decode = %#v, %v; want zero %s", pduType, got.Msg, err, typ)
+		}
+	}
+	for _, pduType := range []string{MsgCancelQueue, MsgListMatches, MsgStopSpectating, MsgReplayEnd, MsgLogout} {
+		if !slices.Contains(binaryTypes, pduType) {
+			t.Errorf("%s has no type byte", pduType)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxFrameSize giới hạn kích thước một PDU (tính cả ký tự xuống dòng).
+	DefaultMaxFrameSize = 64 * 1024
+
+	// DefaultReadTimeout là thời gian tối đa server chờ client gửi gì đó, kể cả
+	// khi người chơi đang chờ lượt trong trận không giới hạn thời gian.
+	DefaultReadTimeout = 30 * time.Minute
+	// DefaultWriteTimeout giới hạn thời gian ghi một PDU tới client.
+	DefaultWriteTimeout = 10 * time.Second
+
+	readChunkSize = 4096
+)
+
+// ErrFrameTooLarge is returned when a peer sends a PDU larger than MaxFrameSize.
+var ErrFrameTooLarge = errors.New("pdu frame exceeds max frame size")
+
+// ErrReadCancelled is returned by ReadPDU after CancelRead.
+var ErrReadCancelled = errors.New("pdu read cancelled")
+
+// Conn wraps a net.Conn with one persistent read buffer and writer so that
+// PDUs arriving back-to-back, or a frame cut short by a deadline or
+// CancelRead, are never lost between ReadPDU calls.
+type Conn struct {
+	net.Conn
+
+	rbuf    []byte // byte đã nhận nhưng chưa tạo thành frame hoàn chỉnh
+	writer  *bufio.Writer
+	writeMu sync.Mutex
+	seq     atomic.Uint64
+	legacy  atomic.Bool
+	pending *PDU // PDU đã đọc nhưng được trả lại bằng Unread
+
+	// mu bảo vệ các thiết lập được thoả thuận trong handshake và cờ huỷ đọc
+	mu         sync.Mutex
+	codec      Codec
+	features   []string
+	negotiated bool // đã thoả thuận feature trong handshake
+	cancelled  bool
+
+	// ReadTimeout và WriteTimeout = 0 nghĩa là không giới hạn thời gian.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	MaxFrameSize int
+}
+
+// NewConn wraps conn with default limits. The connection starts in
+// compatibility mode until the peer sends a versioned PDU.
+func NewConn(conn net.Conn) *Conn {
+	c := &Conn{
+		Conn:         conn,
+		writer:       bufio.NewWriter(conn),
+		MaxFrameSize: DefaultMaxFrameSize,
+		codec:        JSONCodec{},
+	}
+	c.legacy.Store(true)
+	return c
+}
+
+// IsLegacy reports whether the connection is in text compatibility mode.
+func (c *Conn) IsLegacy() bool {
+	return c.legacy.Load()
+}
+
+// SetLegacy switches compatibility mode on or off.
+func (c *Conn) SetLegacy(legacy bool) {
+	c.legacy.Store(legacy)
+}
+
+// Codec returns the codec currently used for framing.
+func (c *Conn) Codec() Codec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.codec
+}
+
+// SetCodec switches framing for all following reads and writes. It is meant
+// to be called right after the handshake, before the next PDU is exchanged.
+func (c *Conn) SetCodec(codec Codec) {
+	c.mu.Lock()
+	c.codec = codec
+	c.mu.Unlock()
+}
+
+// HasFeature reports whether feature was agreed on in the handshake.
+func (c *Conn) HasFeature(feature string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Supports reports whether the peer may use feature: either it was agreed on
+// in the handshake, or the peer never did a handshake (legacy text clients
+// can use every feature).
+func (c *Conn) Supports(feature string) bool {
+	c.mu.Lock()
+	negotiated := c.negotiated
+	c.mu.Unlock()
+	return !negotiated || c.HasFeature(feature)
+}
+
+func (c *Conn) setFeatures(features []string) {
+	c.mu.Lock()
+	c.features = features
+	c.negotiated = true
+	c.mu.Unlock()
+}
+
+// CancelRead makes a ReadPDU blocked in another goroutine (or the next one)
+// return ErrReadCancelled. Bytes already received, including a partial
+// frame, stay available to the next ReadPDU.
+func (c *Conn) CancelRead() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelled = true
+	c.Conn.SetReadDeadline(time.Now())
+}
+
+// ResetCancel clears a CancelRead that no reader consumed.
+func (c *Conn) ResetCancel() {
+	c.mu.Lock()
+	c.cancelled = false
+	c.mu.Unlock()
+}
+
+// ReadResult is one PDU (or read error) delivered by ReadInBackground.
+type ReadResult struct {
+	PDU PDU
+	Err error
+}
+
+// ReadInBackground reads PDUs in a new goroutine until a read fails or stop
+// is called. stop cancels the pending read, pushes back a PDU that was read
+// but not yet received, and returns once the goroutine has exited so the
+// caller can read from the connection directly again.
+func (c *Conn) ReadInBackground() (reads <-chan ReadResult, stop func()) {
+	results := make(chan ReadResult)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			pdu, err := c.ReadPDU()
+			if errors.Is(err, ErrReadCancelled) {
+				return
+			}
+			select {
+			case results <- ReadResult{PDU: pdu, Err: err}:
+			case <-quit:
+				// PDU đọc được lúc dừng thuộc về người đọc tiếp theo
+				if err == nil {
+					c.Unread(pdu)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return results, func() {
+		close(quit)
+		c.CancelRead()
+		<-done
+		c.ResetCancel()
+	}
+}
+
+// Unread pushes pdu back so the next ReadPDU returns it again.
+// Only one PDU can be pushed back at a time.
+func (c *Conn) Unread(pdu PDU) {
+	c.pending = &pdu
+}
+
+// ReadPDU reads exactly one PDU frame from the connection.
+func (c *Conn) ReadPDU() (PDU, error) {
+	if c.pending != nil {
+		pdu := *c.pending
+		c.pending = nil
+		return pdu, nil
+	}
+
+	// Đặt deadline trong mu để không ghi đè deadline của CancelRead
+	c.mu.Lock()
+	if c.cancelled {
+		c.cancelled = false
+		c.mu.Unlock()
+		return PDU{}, ErrReadCancelled
+	}
+	if c.ReadTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	} else {
+		c.Conn.SetReadDeadline(time.Time{})
+	}
+	codec := c.codec
+	c.mu.Unlock()
+
+	pdu, err := c.readFrame(codec)
+	if err != nil {
+		c.mu.Lock()
+		if c.cancelled {
+			c.cancelled = false
+			err = ErrReadCancelled
+		}
+		c.mu.Unlock()
+		return PDU{}, err
+	}
+	// Client gửi envelope có version => chuyển sang chế độ typed
+	if !pdu.IsLegacy() && c.IsLegacy() {
+		c.SetLegacy(false)
+	}
+	return pdu, nil
+}
+
+// readFrame đọc từ kết nối cho tới khi rbuf chứa đủ một frame. Khi đọc lỗi,
+// phần frame đã nhận vẫn nằm trong rbuf cho lần đọc sau.
+func (c *Conn) readFrame(codec Codec) (PDU, error) {
+	for {
+		n, err := codec.Split(c.rbuf, c.MaxFrameSize)
+		if err != nil {
+			return PDU{}, err
+		}
+		if n > 0 {
+			pdu, err := codec.Decode(c.rbuf[:n])
+			c.rbuf = append(c.rbuf[:0], c.rbuf[n:]...)
+			return pdu, err
+		}
+
+		if cap(c.rbuf)-len(c.rbuf) < readChunkSize {
+			c.rbuf = append(make([]byte, 0, len(c.rbuf)+readChunkSize), c.rbuf...)
+		}
+		read, err := c.Conn.Read(c.rbuf[len(c.rbuf):cap(c.rbuf)])
+		c.rbuf = c.rbuf[:len(c.rbuf)+read]
+		// Có dữ liệu mới thì tách frame trước; lỗi sẽ lặp lại ở lần Read sau
+		if err != nil && read == 0 {
+			if err == io.EOF && len(c.rbuf) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return PDU{}, err
+		}
+	}
+}
+
+// SendPDU sends a plain text PDU.
+func (c *Conn) SendPDU(pduType, payload string) error {
+	return c.Send(pduType, payload, nil)
+}
+
+// Send writes a PDU carrying both a human-readable text and a typed message.
+// In compatibility mode only the text is sent, and data-only PDUs are dropped.
+func (c *Conn) Send(pduType, text string, data interface{}) error {
+	return c.Reply(PDU{}, pduType, text, data)
+}
+
+// Reply is like Send but echoes the request id of req for correlation.
+func (c *Conn) Reply(req PDU, pduType, text string, data interface{}) error {
+	pdu := PDU{Type: pduType, Payload: text}
+	if c.IsLegacy() {
+		if text == "" {
+			return nil
+		}
+		return c.WritePDU(pdu)
+	}
+
+	pdu.Version = ProtocolVersion
+	pdu.RequestID = req.RequestID
+	pdu.Msg = data
+	return c.WritePDU(pdu)
+}
+
+// WritePDU encodes and writes a PDU as-is, assigning the next sequence
+// number for versioned PDUs, and flushes it immediately.
+func (c *Conn) WritePDU(pdu PDU) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if !pdu.IsLegacy() {
+		pdu.Seq = c.seq.Add(1)
+	}
+	data, err := c.Codec().Encode(pdu)
+	if err != nil {
+		return err
+	}
+	if c.MaxFrameSize > 0 && len(data) > c.MaxFrameSize {
+		return fmt.Errorf("send %s: %w", pdu.Type, ErrFrameTooLarge)
+	}
+
+	if c.WriteTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	} else {
+		c.Conn.SetWriteDeadline(time.Time{})
+	}
+	if _, err := c.writer.Write(data); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
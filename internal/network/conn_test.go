@@ -0,0 +1,130 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// pipe trả về hai đầu Conn nối với nhau, cùng dùng codec.
+func pipe(t *testing.T, codec Codec) (server, client *Conn, raw net.Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	server, client = NewConn(a), NewConn(b)
+	server.SetCodec(codec)
+	client.SetCodec(codec)
+	return server, client, b
+}
+
+func encode(t *testing.T, codec Codec, pdus ...PDU) []byte {
+	t.Helper()
+	var out []byte
+	for _, pdu := range pdus {
+		frame, err := codec.Encode(pdu)
+		if err != nil {
+			t.Fatalf("encode %v: %v", pdu, err)
+		}
+		out = append(out, frame...)
+	}
+	return out
+}
+
+// write ghi data trong goroutine riêng vì net.Pipe chặn tới khi đầu kia đọc.
+func write(raw net.Conn, data []byte) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := raw.Write(data)
+		done <- err
+	}()
+	return done
+}
+
+func TestConnReadsBackToBackFrames(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			server, _, raw := pipe(t, codec)
+			first := PDU{Version: ProtocolVersion, Seq: 1, Type: "input", Payload: "1"}
+			second := PDU{Version: ProtocolVersion, Seq: 2, Type: MsgAttackRequest, Data: []byte(`{"troop":"Knight","tower":1}`)}
+			done := write(raw, encode(t, codec, first, second))
+
+			for _, want := range []PDU{first, second} {
+				got, err := server.ReadPDU()
+				if err != nil {
+					t.Fatalf("ReadPDU: %v", err)
+				}
+				if got.Type != want.Type || got.Payload != want.Payload || got.Seq != want.Seq || string(got.Data) != string(want.Data) {
+					t.Fatalf("ReadPDU = %+v, want %+v", got, want)
+				}
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		})
+	}
+}
+
+func TestConnKeepsPartialFrame(t *testing.T) {
+	interrupt := map[string]func(c *Conn){
+		"deadline": func(c *Conn) { c.ReadTimeout = 50 * time.Millisecond },
+		"cancel":   func(c *Conn) { time.AfterFunc(50*time.Millisecond, c.CancelRead) },
+	}
+	for _, codec := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		for name, stop := range interrupt {
+			t.Run(codec.Name()+"/"+name, func(t *testing.T) {
+				server, _, raw := pipe(t, codec)
+				want := PDU{Version: ProtocolVersion, Seq: 7, Type: "input", Payload: "attack the king tower"}
+				frame := encode(t, codec, want)
+				half := len(frame) / 2
+
+				done := write(raw, frame[:half])
+				stop(server)
+				_, err := server.ReadPDU()
+				if !errors.Is(err, ErrReadCancelled) && !errors.Is(err, os.ErrDeadlineExceeded) {
+					t.Fatalf("ReadPDU on partial frame = %v, want cancel or deadline", err)
+				}
+				if err := <-done; err != nil {
+					t.Fatalf("write: %v", err)
+				}
+
+				server.ReadTimeout = 0
+				done = write(raw, frame[half:])
+				got, err := server.ReadPDU()
+				if err != nil {
+					t.Fatalf("ReadPDU after rest of frame: %v", err)
+				}
+				if got.Payload != want.Payload || got.Seq != want.Seq {
+					t.Fatalf("ReadPDU = %+v, want %+v", got, want)
+				}
+				<-done
+			})
+		}
+	}
+}
+
+func TestConnUnexpectedEOF(t *testing.T) {
+	server, _, raw := pipe(t, JSONCodec{})
+	go func() {
+		raw.Write([]byte(`{"type":"inp`))
+		raw.Close()
+	}()
+	if _, err := server.ReadPDU(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ReadPDU on truncated stream = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestConnRejectsLargeFrame(t *testing.T) {
+	server, client, _ := pipe(t, JSONCodec{})
+	server.MaxFrameSize = 32
+	client.SetLegacy(false)
+	go client.SendPDU("input", "this payload is far longer than thirty-two bytes")
+	if _, err := server.ReadPDU(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("ReadPDU = %v, want ErrFrameTooLarge", err)
+	}
+}
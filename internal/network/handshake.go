@@ -0,0 +1,164 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	MsgHello   = "hello"
+	MsgWelcome = "welcome"
+
+	// HandshakeTimeout là thời gian server chờ "hello" trước khi coi client là client cũ.
+	HandshakeTimeout = 1 * time.Second
+)
+
+// Feature flags được thoả thuận trong handshake.
+const (
+	FeatureTimed     = "timed"
+	FeatureSpectate  = "spectate"
+	FeatureReconnect = "reconnect"
+	FeatureRealtime  = "realtime"
+)
+
+// SupportedVersions lists the protocol versions this build can speak.
+var SupportedVersions = []int{ProtocolVersion}
+
+// Hello is the first PDU a client sends.
+type Hello struct {
+	ClientName    string   `json:"clientName"`
+	ClientVersion string   `json:"clientVersion"`
+	Versions      []int    `json:"versions"`
+	Codecs        []string `json:"codecs"`
+	Features      []string `json:"features"`
+	SessionToken  string   `json:"sessionToken,omitempty"` // token từ lần đăng nhập trước
+}
+
+// Welcome is the server's answer to Hello with the negotiated settings.
+type Welcome struct {
+	ServerName string   `json:"serverName"`
+	Version    int      `json:"version"`
+	Codec      string   `json:"codec"`
+	Features   []string `json:"features"`
+}
+
+// ProtocolError is sent before the server closes a connection it cannot speak to.
+type ProtocolError struct {
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Versions []int    `json:"versions,omitempty"`
+	Codecs   []string `json:"codecs,omitempty"`
+}
+
+// ErrHandshakeRejected is returned when client and server have nothing in common.
+var ErrHandshakeRejected = errors.New("handshake rejected")
+
+// AcceptHandshake waits briefly for a Hello and answers with a Welcome.
+// Clients that send nothing in time are treated as legacy text clients and
+// get a nil Hello with no error. serverFeatures are the flags this server offers.
+func (c *Conn) AcceptHandshake(serverName string, serverFeatures []string) (*Hello, error) {
+	timeout := c.ReadTimeout
+	c.ReadTimeout = HandshakeTimeout
+	pdu, err := c.ReadPDU()
+	c.ReadTimeout = timeout
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if pdu.Type != MsgHello {
+		// Client cũ gửi input trước khi có menu: giữ lại PDU này cho lần đọc tiếp theo
+		c.SetLegacy(true)
+		c.Unread(pdu)
+		return nil, nil
+	}
+
+	var hello Hello
+	if err := pdu.DecodeData(&hello); err != nil {
+		c.rejectHandshake(pdu, "bad_hello", "❌ Malformed hello.")
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeRejected, err)
+	}
+
+	version := 0
+	for _, v := range hello.Versions {
+		for _, sv := range SupportedVersions {
+			if v == sv && v > version {
+				version = v
+			}
+		}
+	}
+	if version == 0 {
+		c.rejectHandshake(pdu, "unsupported_version",
+			fmt.Sprintf("❌ Unsupported protocol version %v. Server speaks %v.", hello.Versions, SupportedVersions))
+		return nil, fmt.Errorf("%w: versions %v", ErrHandshakeRejected, hello.Versions)
+	}
+
+	codec, err := NegotiateCodec(hello.Codecs)
+	if err != nil {
+		c.rejectHandshake(pdu, "unsupported_codec",
+			fmt.Sprintf("❌ No common codec. Server supports %v.", SupportedCodecs()))
+		return nil, fmt.Errorf("%w: %v", ErrHandshakeRejected, err)
+	}
+
+	var features []string
+	for _, f := range hello.Features {
+		for _, sf := range serverFeatures {
+			if f == sf {
+				features = append(features, f)
+			}
+		}
+	}
+
+	welcome := Welcome{ServerName: serverName, Version: version, Codec: codec.Name(), Features: features}
+	if err := c.Reply(pdu, MsgWelcome, "", welcome); err != nil {
+		return nil, err
+	}
+	c.SetCodec(codec)
+	c.setFeatures(features)
+	return &hello, nil
+}
+
+// rejectHandshake gửi lỗi có kiểu (luôn bằng JSON) trước khi đóng kết nối.
+func (c *Conn) rejectHandshake(req PDU, code, message string) {
+	c.Reply(req, "error", message, ProtocolError{
+		Code:     code,
+		Message:  message,
+		Versions: SupportedVersions,
+		Codecs:   SupportedCodecs(),
+	})
+}
+
+// ClientHandshake sends hello and waits for the server's welcome, switching
+// to the negotiated codec on success.
+func (c *Conn) ClientHandshake(hello Hello) (Welcome, error) {
+	c.SetLegacy(false)
+	if err := c.Send(MsgHello, "", hello); err != nil {
+		return Welcome{}, err
+	}
+	pdu, err := c.ReadPDU()
+	if err != nil {
+		return Welcome{}, err
+	}
+	if pdu.Type != MsgWelcome {
+		var perr ProtocolError
+		if pdu.DecodeData(&perr) == nil && perr.Code != "" {
+			return Welcome{}, fmt.Errorf("%w: %s", ErrHandshakeRejected, perr.Message)
+		}
+		return Welcome{}, fmt.Errorf("%w: unexpected %q", ErrHandshakeRejected, pdu.Type)
+	}
+
+	var welcome Welcome
+	if err := pdu.DecodeData(&welcome); err != nil {
+		return Welcome{}, err
+	}
+	codec, ok := LookupCodec(welcome.Codec)
+	if !ok {
+		return Welcome{}, fmt.Errorf("%w: unknown codec %q", ErrHandshakeRejected, welcome.Codec)
+	}
+	c.SetCodec(codec)
+	c.setFeatures(welcome.Features)
+	return welcome, nil
+}
@@ -0,0 +1,399 @@
+package network
+
+import (
+	"reflect"
+	"time"
+)
+
+// Các loại PDU có dữ liệu kiểu (typed). Payload vẫn mang văn bản cho client cũ.
+const (
+	MsgAttackRequest  = "attack_request"
+	MsgAttackResult   = "attack_result"
+	MsgHealResult     = "heal_result"
+	MsgTowerDestroyed = "tower_destroyed"
+	MsgTurnStarted    = "turn_started"
+	MsgMatchFound     = "match_found"
+	MsgStatusSnapshot = "status_snapshot"
+	MsgGameOver       = "game_over"
+	MsgSessionToken   = "session_token"
+	MsgLogout         = "logout"
+
+	MsgPlayerDisconnected = "player_disconnected"
+	MsgPlayerReconnected  = "player_reconnected"
+	MsgStateResync        = "state_resync"
+
+	MsgDeploy     = "deploy"
+	MsgStateDelta = "state_delta"
+
+	MsgDeck     = "deck"
+	MsgSaveDeck = "save_deck"
+
+	MsgUpgrade     = "upgrade"
+	MsgUpgradeInfo = "upgrade_info"
+
+	MsgCreatePrivate  = "create_private"
+	MsgPrivateCreated = "private_created"
+	MsgJoinPrivate    = "join_private"
+
+	MsgQueueStatus = "queue_status"
+	MsgCancelQueue = "cancel_queue"
+
+	MsgListMatches    = "list_matches"
+	MsgMatchList      = "match_list"
+	MsgSpectate       = "spectate"
+	MsgSpectateState  = "spectate_state"
+	MsgStopSpectating = "stop_spectating"
+
+	MsgReplay        = "replay"
+	MsgReplayList    = "replay_list"
+	MsgReplayStart   = "replay_start" // data là header của file replay
+	MsgReplayControl = "replay_control"
+	MsgReplayEnd     = "replay_end"
+
+	MsgPlayBot = "play_bot"
+
+	MsgMatchHistory = "match_history"
+)
+
+// AttackRequest is sent by a client to attack without going through the text menus.
+type AttackRequest struct {
+	Troop   string `json:"troop"`   // tên troop trên tay
+	Tower   int    `json:"tower"`   // vị trí tower của đối thủ (bắt đầu từ 0)
+	UseCrit bool   `json:"useCrit"` // dùng một lượt CRIT
+}
+
+// AttackResult describes the outcome of one attack. Damage is the total
+// the troop dealt to the target tower over all combat rounds.
+type AttackResult struct {
+	Attacker  string      `json:"attacker"`
+	Defender  string      `json:"defender"`
+	Troop     string      `json:"troop"`
+	Tower     string      `json:"tower"`
+	Index     int         `json:"index"`
+	Damage    int         `json:"damage"`
+	Crit      bool        `json:"crit"`
+	TowerHP   int         `json:"towerHp"`
+	TroopHP   int         `json:"troopHp"`
+	TroopDied bool        `json:"troopDied"`
+	Log       []CombatHit `json:"log,omitempty"`
+}
+
+// CombatHit là một đòn đánh trong combat log (troop đánh tower hoặc ngược lại).
+type CombatHit struct {
+	Round    int    `json:"round"`
+	Attacker string `json:"attacker"`
+	Target   string `json:"target"`
+	Damage   int    `json:"damage"`
+	Crit     bool   `json:"crit"`
+	HPLeft   int    `json:"hpLeft"`
+	Effect   string `json:"effect,omitempty"` // ability gây ra đòn này: "splash", "dot", ...
+}
+
+// HealResult describes a heal applied to one of the player's own towers.
+type HealResult struct {
+	Player string `json:"player"`
+	Troop  string `json:"troop"`
+	Tower  string `json:"tower"`
+	Index  int    `json:"index"`
+	Amount int    `json:"amount"`
+	FromHP int    `json:"fromHp"`
+	ToHP   int    `json:"toHp"`
+}
+
+// TowerDestroyed is broadcast when a tower's HP reaches zero.
+type TowerDestroyed struct {
+	Owner string `json:"owner"`
+	Tower string `json:"tower"`
+	Index int    `json:"index"`
+}
+
+// TurnStarted is broadcast at the beginning of every turn.
+type TurnStarted struct {
+	Player      string `json:"player"`
+	TimeLeftSec int    `json:"timeLeftSec,omitempty"` // chỉ có ở Timed Game
+}
+
+// MatchFound is broadcast once both players are in a game session.
+type MatchFound struct {
+	Player1   string `json:"player1"`
+	Player2   string `json:"player2"`
+	FirstTurn string `json:"firstTurn,omitempty"` // rỗng ở chế độ real-time
+	Timed     bool   `json:"timed"`
+	Mode      string `json:"mode"` // "untimed", "timed" hoặc "realtime"
+}
+
+// TowerState là trạng thái của một tower trong StatusSnapshot.
+type TowerState struct {
+	Type string `json:"type"`
+	HP   int    `json:"hp"`
+	ATK  int    `json:"atk"`
+	DEF  int    `json:"def"`
+}
+
+// TroopCard là một troop trên tay người chơi.
+type TroopCard struct {
+	Name    string `json:"name"`
+	ATK     int    `json:"atk"`
+	DEF     int    `json:"def"`
+	Mana    int    `json:"mana"`
+	Special string `json:"special,omitempty"`
+}
+
+// StatusSnapshot is the structured form of the "Show Status" screen.
+type StatusSnapshot struct {
+	Username  string       `json:"username"`
+	Level     int          `json:"level"`
+	EXP       int          `json:"exp"`
+	Rating    int          `json:"rating"`
+	Mana      int          `json:"mana"`
+	CritsLeft int          `json:"critsLeft"`
+	Towers    []TowerState `json:"towers"`
+	Troops    []TroopCard  `json:"troops"`
+	Next      *TroopCard   `json:"next,omitempty"` // card vào tay sau lần chơi kế tiếp
+}
+
+// DeckInfo is the player's saved deck and card collection, sent in reply to
+// MsgDeck and MsgSaveDeck.
+type DeckInfo struct {
+	Deck       []string       `json:"deck"`
+	Collection map[string]int `json:"collection"` // số bản của mỗi card
+}
+
+// SaveDeck replaces the player's deck; Cards must hold exactly 8 owned cards.
+type SaveDeck struct {
+	Cards []string `json:"cards"`
+}
+
+// UpgradeRequest levels up one card or tower type. An empty Name only asks
+// for the current levels.
+type UpgradeRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+// LevelState là cấp hiện tại của một card hoặc loại tower và chi phí lên cấp tiếp theo.
+type LevelState struct {
+	Name     string `json:"name"`
+	Level    int    `json:"level"`
+	EXP      int    `json:"exp"`
+	NextEXP  int    `json:"nextExp,omitempty"`
+	NextGold int    `json:"nextGold,omitempty"`
+	Max      bool   `json:"max,omitempty"`
+}
+
+// UpgradeInfo is sent in reply to MsgUpgrade.
+type UpgradeInfo struct {
+	Gold   int          `json:"gold"`
+	Cards  []LevelState `json:"cards"`
+	Towers []LevelState `json:"towers"`
+}
+
+// CreatePrivate asks for a private match with custom rules. Zero fields keep
+// the server's rules.
+type CreatePrivate struct {
+	Mode        string   `json:"mode,omitempty"` // "untimed" (mặc định), "timed" hoặc "realtime"
+	DurationSec int      `json:"durationSec,omitempty"`
+	StartMana   *int     `json:"startMana,omitempty"`
+	Cards       []string `json:"cards,omitempty"` // chỉ cho phép các card này
+}
+
+// PlayBot starts a practice match against a server-side bot.
+type PlayBot struct {
+	Difficulty string `json:"difficulty,omitempty"` // "easy", "normal" (mặc định) hoặc "hard"
+}
+
+// PrivateCreated returns the invite code the host shares with the second player.
+type PrivateCreated struct {
+	Code         string `json:"code"`
+	Mode         string `json:"mode"`
+	ExpiresInSec int    `json:"expiresInSec"`
+}
+
+// JoinPrivate joins the private match with the given invite code.
+type JoinPrivate struct {
+	Code string `json:"code"`
+}
+
+// QueueStatus is sent periodically while a player waits for an opponent,
+// and in reply to a queue_status request.
+type QueueStatus struct {
+	Mode      string `json:"mode"`
+	Position  int    `json:"position"` // bắt đầu từ 1
+	Queued    int    `json:"queued"`
+	WaitedSec int    `json:"waitedSec"`
+	Rating    int    `json:"rating"`
+	Window    int    `json:"window"` // chênh lệch rating đang chấp nhận
+}
+
+// LiveMatch là một trận đang diễn ra có thể xem.
+type LiveMatch struct {
+	Player1    string `json:"player1"`
+	Player2    string `json:"player2"`
+	Mode       string `json:"mode"`
+	Spectators int    `json:"spectators"`
+}
+
+// MatchList replies to list_matches.
+type MatchList struct {
+	Matches []LiveMatch `json:"matches"`
+}
+
+// Spectate asks to watch the match the given player is in.
+type Spectate struct {
+	Player string `json:"player"`
+}
+
+// SpectateState carries the full match state to a spectator when they join
+// and when they ask for status. Hands are empty unless the match's rules
+// allow spectators to see them.
+type SpectateState struct {
+	Mode        string         `json:"mode"`
+	TurnOwner   string         `json:"turnOwner,omitempty"`
+	TimeLeftSec int            `json:"timeLeftSec,omitempty"`
+	Player1     StatusSnapshot `json:"player1"`
+	Player2     StatusSnapshot `json:"player2"`
+	Units       []UnitState    `json:"units,omitempty"` // chỉ có ở chế độ real-time
+}
+
+// ReplayRequest asks to play a replay back; an empty ID lists the player's replays.
+type ReplayRequest struct {
+	ID    string  `json:"id,omitempty"`
+	Speed float64 `json:"speed,omitempty"` // mặc định 1
+}
+
+// ReplayInfo mô tả một replay trong ReplayList.
+type ReplayInfo struct {
+	ID        string    `json:"id"`
+	Player1   string    `json:"player1"`
+	Player2   string    `json:"player2"`
+	Mode      string    `json:"mode"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// ReplayList replies to a replay request without an ID.
+type ReplayList struct {
+	Replays []ReplayInfo `json:"replays"`
+}
+
+// ReplayControl changes the speed of, or stops, the replay being played.
+type ReplayControl struct {
+	Speed float64 `json:"speed,omitempty"`
+	Stop  bool    `json:"stop,omitempty"`
+}
+
+// MatchResult là một trận trong lịch sử đấu, nhìn từ phía người chơi hỏi.
+type MatchResult struct {
+	ID        int64     `json:"id"`
+	Opponent  string    `json:"opponent"`
+	Mode      string    `json:"mode"`
+	Result    string    `json:"result"` // "win", "loss", "draw" hoặc "unfinished"
+	Reason    string    `json:"reason,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// MatchHistory replies to a match_history request with the player's recent results.
+type MatchHistory struct {
+	Matches []MatchResult `json:"matches"`
+}
+
+// GameOver is broadcast when a match ends.
+type GameOver struct {
+	Winner  string         `json:"winner,omitempty"`  // rỗng nếu hoà
+	Reason  string         `json:"reason"`            // "king_destroyed", "time_up", "disconnect"
+	Ratings map[string]int `json:"ratings,omitempty"` // rating mới theo username
+}
+
+// SessionToken is sent after login; the client may present it in a later Hello.
+type SessionToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PlayerConnection reports that a player dropped or came back during a match.
+type PlayerConnection struct {
+	Player   string `json:"player"`
+	GraceSec int    `json:"graceSec,omitempty"` // thời gian còn lại để kết nối lại
+}
+
+// StateResync carries the full match state to a player who just reconnected.
+// The opponent's hand is always empty.
+type StateResync struct {
+	Player1     string         `json:"player1"`
+	Player2     string         `json:"player2"`
+	TurnOwner   string         `json:"turnOwner,omitempty"`
+	TimeLeftSec int            `json:"timeLeftSec,omitempty"`
+	You         StatusSnapshot `json:"you"`
+	Opponent    StatusSnapshot `json:"opponent"`
+	Units       []UnitState    `json:"units,omitempty"` // chỉ có ở chế độ real-time
+}
+
+// Deploy is sent by a client in a real-time match to put a troop on the field.
+type Deploy struct {
+	Troop string `json:"troop"`          // tên troop trên tay
+	Lane  string `json:"lane,omitempty"` // "left" (mặc định) hoặc "right"
+}
+
+// UnitState là một troop đang ở trên sân trong trận real-time.
+type UnitState struct {
+	ID    int     `json:"id"`
+	Owner string  `json:"owner"`
+	Troop string  `json:"troop"`
+	Lane  string  `json:"lane"`
+	Pos   float64 `json:"pos"`   // toạ độ trên lane, 0 = King Tower của player1
+	State string  `json:"state"` // "walking" hoặc "attacking"
+	HP    int     `json:"hp"`
+}
+
+// TowerUpdate reports a tower's new HP.
+type TowerUpdate struct {
+	Owner string `json:"owner"`
+	Index int    `json:"index"`
+	HP    int    `json:"hp"`
+}
+
+// StateDelta is sent after every simulation tick that changed something.
+// Mana is the receiving player's own mana.
+type StateDelta struct {
+	Tick    uint64        `json:"tick"`
+	Mana    int           `json:"mana"`
+	Units   []UnitState   `json:"units,omitempty"`
+	Removed []int         `json:"removed,omitempty"` // id các troop đã chết
+	Towers  []TowerUpdate `json:"towers,omitempty"`
+}
+
+// messageTypes là kiểu dữ liệu của từng loại PDU có dữ liệu kiểu. BinaryCodec
+// mã hoá thẳng các field của kiểu này; dữ liệu khác kiểu đã đăng ký (vd. header
+// replay) được gửi dưới dạng JSON.
+var messageTypes = map[string]reflect.Type{
+	MsgHello:              reflect.TypeFor[Hello](),
+	MsgWelcome:            reflect.TypeFor[Welcome](),
+	MsgAttackRequest:      reflect.TypeFor[AttackRequest](),
+	MsgAttackResult:       reflect.TypeFor[AttackResult](),
+	MsgHealResult:         reflect.TypeFor[HealResult](),
+	MsgTowerDestroyed:     reflect.TypeFor[TowerDestroyed](),
+	MsgTurnStarted:        reflect.TypeFor[TurnStarted](),
+	MsgMatchFound:         reflect.TypeFor[MatchFound](),
+	MsgStatusSnapshot:     reflect.TypeFor[StatusSnapshot](),
+	MsgGameOver:           reflect.TypeFor[GameOver](),
+	MsgSessionToken:       reflect.TypeFor[SessionToken](),
+	MsgPlayerDisconnected: reflect.TypeFor[PlayerConnection](),
+	MsgPlayerReconnected:  reflect.TypeFor[PlayerConnection](),
+	MsgStateResync:        reflect.TypeFor[StateResync](),
+	MsgDeploy:             reflect.TypeFor[Deploy](),
+	MsgStateDelta:         reflect.TypeFor[StateDelta](),
+	MsgDeck:               reflect.TypeFor[DeckInfo](),
+	MsgSaveDeck:           reflect.TypeFor[SaveDeck](),
+	MsgUpgrade:            reflect.TypeFor[UpgradeRequest](),
+	MsgUpgradeInfo:        reflect.TypeFor[UpgradeInfo](),
+	MsgCreatePrivate:      reflect.TypeFor[CreatePrivate](),
+	MsgPrivateCreated:     reflect.TypeFor[PrivateCreated](),
+	MsgJoinPrivate:        reflect.TypeFor[JoinPrivate](),
+	MsgQueueStatus:        reflect.TypeFor[QueueStatus](),
+	MsgMatchList:          reflect.TypeFor[MatchList](),
+	MsgSpectate:           reflect.TypeFor[Spectate](),
+	MsgSpectateState:      reflect.TypeFor[SpectateState](),
+	MsgReplay:             reflect.TypeFor[ReplayRequest](),
+	MsgReplayList:         reflect.TypeFor[ReplayList](),
+	MsgReplayControl:      reflect.TypeFor[ReplayControl](),
+	MsgPlayBot:            reflect.TypeFor[PlayBot](),
+	MsgMatchHistory:       reflect.TypeFor[MatchHistory](),
+}
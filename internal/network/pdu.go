@@ -1,24 +1,94 @@
 package network
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"net"
-	"strings"
+	"reflect"
 )
 
+// ProtocolVersion là phiên bản envelope hiện tại. Version 0 = PDU văn bản kiểu cũ.
+const ProtocolVersion = 1
+
 // PDU đại diện cho một tin nhắn truyền qua mạng
 type PDU struct {
-	Type    string `json:"type"`
-	Payload string `json:"payload"` // Nội dung cụ thể
+	Version   int             `json:"v,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+	RequestID string          `json:"rid,omitempty"`
+	Type      string          `json:"type"`
+	Payload   string          `json:"payload"`        // Nội dung văn bản (dành cho client cũ)
+	Data      json.RawMessage `json:"data,omitempty"` // Message có kiểu, mã hoá thành JSON object
+
+	// Msg là message có kiểu chưa mã hoá. Khi gửi, codec mã hoá Msg thay cho
+	// Data; BinaryCodec giải mã dữ liệu có kiểu đã đăng ký vào Msg thay vì Data.
+	Msg interface{} `json:"-"`
+}
+
+// IsLegacy reports whether the PDU uses the old {type, payload} format.
+func (p PDU) IsLegacy() bool {
+	return p.Version == 0
+}
+
+// HasData reports whether the PDU carries a typed body.
+func (p PDU) HasData() bool {
+	return p.Msg != nil || len(p.Data) > 0
+}
+
+// Body trả về dữ liệu có kiểu của PDU (Msg hoặc Data), nil nếu không có.
+func (p PDU) Body() interface{} {
+	switch {
+	case p.Msg != nil:
+		return p.Msg
+	case len(p.Data) > 0:
+		return p.Data
+	}
+	return nil
+}
+
+// DecodeData unmarshals the typed body of the PDU into v.
+func (p PDU) DecodeData(v interface{}) error {
+	if !p.HasData() {
+		return fmt.Errorf("pdu %q has no data", p.Type)
+	}
+	data := p.Data
+	if p.Msg != nil {
+		// Đã giải mã sẵn: gán thẳng nếu cùng kiểu, nếu không thì đi qua JSON
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Type() == reflect.TypeOf(p.Msg) {
+			rv.Elem().Set(reflect.ValueOf(p.Msg))
+			return nil
+		}
+		raw, err := encodeMsg(p)
+		if err != nil {
+			return err
+		}
+		data = raw
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %q data: %w", p.Type, err)
+	}
+	return nil
 }
 
 // EncodePDU chuyển PDU thành []byte để gửi đi
 func EncodePDU(pdu PDU) ([]byte, error) {
+	if pdu.Msg != nil {
+		raw, err := encodeMsg(pdu)
+		if err != nil {
+			return nil, err
+		}
+		pdu.Data = raw
+	}
 	return json.Marshal(pdu)
 }
 
+// encodeMsg mã hoá pdu.Msg thành JSON.
+func encodeMsg(pdu PDU) (json.RawMessage, error) {
+	raw, err := json.Marshal(pdu.Msg)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", pdu.Type, err)
+	}
+	return raw, nil
+}
+
 // DecodePDU chuyển []byte thành PDU (sau khi nhận được)
 func DecodePDU(data []byte) (PDU, error) {
 	var pdu PDU
@@ -28,22 +98,3 @@ func DecodePDU(data []byte) (PDU, error) {
 	}
 	return pdu, nil
 }
-func SendPDU(conn net.Conn, pduType, payload string) error {
-	pdu := PDU{Type: pduType, Payload: payload}
-	data, err := EncodePDU(pdu)
-	if err != nil {
-		return err
-	}
-	data = append(data, '\n') // để client đọc được dòng
-	_, err = conn.Write(data)
-	return err
-}
-
-func ReadPDU(conn net.Conn) (PDU, error) {
-	reader := bufio.NewReader(conn)
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return PDU{}, err
-	}
-	return DecodePDU([]byte(strings.TrimSpace(line)))
-}
@@ -6,8 +6,9 @@ import (
 )
 
 // StartTCPServer starts a TCP server on the given port.
-// For each accepted connection, it starts a new goroutine and calls the provided handler.
-func StartTCPServer(port string, handleConn func(net.Conn)) {
+// For each accepted connection, it wraps it in a Conn with the default read
+// and write deadlines, starts a new goroutine and calls the provided handler.
+func StartTCPServer(port string, handleConn func(*Conn)) {
 	address := ":" + port
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
@@ -26,6 +27,9 @@ func StartTCPServer(port string, handleConn func(net.Conn)) {
 		}
 
 		// Launch a new goroutine for each client
-		go handleConn(conn)
+		c := NewConn(conn)
+		c.ReadTimeout = DefaultReadTimeout
+		c.WriteTimeout = DefaultWriteTimeout
+		go handleConn(c)
 	}
 }
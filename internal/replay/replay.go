@@ -0,0 +1,206 @@
+// Package replay ghi lại một trận đấu thành file JSON Lines và đọc lại để
+// phát cho client. Dòng đầu là Header (trạng thái ban đầu), mỗi dòng sau là
+// một Entry có mốc thời gian tính từ lúc bắt đầu trận.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+	"net-centric-clash-royale/internal/network"
+)
+
+// ErrNotFound is returned by Load when no replay has the given id.
+var ErrNotFound = errors.New("replay not found")
+
+// Loại entry trong replay.
+const (
+	KindAction = "action" // lệnh người chơi gửi lên
+	KindEvent  = "event"  // sự kiện công khai server gửi cho cả trận
+)
+
+// PlayerState là trạng thái ban đầu của một người chơi: tower, bài trên tay
+// và thứ tự các card còn lại trong hàng chờ rút bài.
+type PlayerState struct {
+	network.StatusSnapshot
+	DrawPile []network.TroopCard `json:"drawPile"`
+}
+
+// Header là dòng đầu của file replay.
+type Header struct {
+	ID        string         `json:"id"`
+	Player1   string         `json:"player1"`
+	Player2   string         `json:"player2"`
+	Mode      string         `json:"mode"`
+	Seed      int64          `json:"seed"`
+	StartedAt time.Time      `json:"startedAt"`
+	Rules     models.Ruleset `json:"rules"`
+	Players   []PlayerState  `json:"players"`
+}
+
+// Entry là một hành động hoặc sự kiện của trận đấu.
+type Entry struct {
+	AtMs   int64           `json:"at"` // mili giây từ lúc bắt đầu trận
+	Kind   string          `json:"kind"`
+	Type   string          `json:"type"`
+	Player string          `json:"player,omitempty"`
+	Text   string          `json:"text,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Recorder ghi replay của một trận. Không an toàn khi dùng đồng thời; trận
+// đấu chỉ ghi từ vòng lặp của nó.
+type Recorder struct {
+	file  *os.File
+	enc   *json.Encoder
+	start time.Time
+	path  string
+}
+
+// Create tạo file replay mới trong dir và ghi header; h.ID được đặt theo
+// thời gian bắt đầu và tên hai người chơi. File mang đuôi ".part" cho tới khi
+// Close, để không ai xem được seed và thứ tự bài của trận đang diễn ra.
+func Create(dir string, h Header) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%s-%s-vs-%s", h.StartedAt.Format("20060102-150405"), safeName(h.Player1), safeName(h.Player2))
+	for n := 1; ; n++ {
+		h.ID = base
+		if n > 1 {
+			h.ID = fmt.Sprintf("%s-%d", base, n)
+		}
+		if _, err := os.Stat(path(dir, h.ID)); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(path(dir, h.ID)+".part", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r := &Recorder{file: f, enc: json.NewEncoder(f), start: h.StartedAt, path: path(dir, h.ID)}
+		if err := r.enc.Encode(h); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// Record ghi một entry; data được lưu dưới dạng JSON.
+func (r *Recorder) Record(kind, pduType, player, text string, data interface{}) error {
+	e := Entry{
+		AtMs:   time.Since(r.start).Milliseconds(),
+		Kind:   kind,
+		Type:   pduType,
+		Player: player,
+		Text:   text,
+	}
+	switch d := data.(type) {
+	case nil:
+	case json.RawMessage:
+		if len(d) > 0 {
+			e.Data = d
+		}
+	default:
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		e.Data = raw
+	}
+	return r.enc.Encode(e)
+}
+
+// Close đóng file replay và cho phép xem lại nó.
+func (r *Recorder) Close() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(r.file.Name(), r.path)
+}
+
+// Load đọc toàn bộ replay có mã id trong dir.
+func Load(dir, id string) (Header, []Entry, error) {
+	var h Header
+	if !validID(id) {
+		return h, nil, ErrNotFound
+	}
+	f, err := os.Open(path(dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil, ErrNotFound
+	}
+	if err != nil {
+		return h, nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	if err := dec.Decode(&h); err != nil {
+		return h, nil, fmt.Errorf("read replay header: %w", err)
+	}
+	var entries []Entry
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			// Server dừng giữa chừng có thể để lại dòng cuối dở dang
+			break
+		}
+		entries = append(entries, e)
+	}
+	return h, entries, nil
+}
+
+// List trả về header các replay có username tham gia, mới nhất trước.
+func List(dir, username string) ([]Header, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var headers []Header
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		var h Header
+		err = json.NewDecoder(f).Decode(&h)
+		f.Close()
+		if err != nil || (h.Player1 != username && h.Player2 != username) {
+			continue
+		}
+		headers = append(headers, h)
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].StartedAt.After(headers[j].StartedAt) })
+	return headers, nil
+}
+
+func path(dir, id string) string {
+	return filepath.Join(dir, id+".jsonl")
+}
+
+// validID chặn mã replay trỏ ra ngoài thư mục replay.
+func validID(id string) bool {
+	return id != "" && safeName(id) == id
+}
+
+// safeName thay các ký tự không dùng được trong tên file bằng '_'.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
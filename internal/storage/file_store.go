@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// FileStore keeps all players in memory and rewrites a JSON file atomically
+// (temp file + fsync + rename) on every change. Like SQLStore it only hands
+// out and stores copies, so save never reads a player a match is changing.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	players map[string]*models.Player
+}
+
+// NewFileStore loads players from path. A missing file means an empty store.
+func NewFileStore(path string) (*FileStore, error) {
+	store := &FileStore{
+		path:    path,
+		players: make(map[string]*models.Player),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&store.players); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if store.players == nil {
+		store.players = make(map[string]*models.Player)
+	}
+	return store, nil
+}
+
+func (s *FileStore) Get(username string) (*models.Player, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	player, ok := s.players[username]
+	if !ok {
+		return nil, false
+	}
+	return player.Clone(), true
+}
+
+func (s *FileStore) Put(player *models.Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.players[player.Username] = player.Clone()
+	return s.save()
+}
+
+func (s *FileStore) List() []*models.Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*models.Player, 0, len(s.players))
+	for _, p := range s.players {
+		list = append(list, p.Clone())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+func (s *FileStore) Update(username string, fn func(*models.Player) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	player, ok := s.players[username]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	// fn sửa bản sao để khi lỗi dữ liệu trong store không đổi
+	player = player.Clone()
+	if err := fn(player); err != nil {
+		return err
+	}
+	s.players[username] = player
+	return s.save()
+}
+
+func (s *FileStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.players[username]; !ok {
+		return ErrPlayerNotFound
+	}
+	delete(s.players, username)
+	return s.save()
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save phải được gọi khi đang giữ s.mu.
+func (s *FileStore) save() error {
+	data, err := json.Marshal(s.players)
+	if err != nil {
+		return fmt.Errorf("failed to encode player data: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic ghi ra file tạm cùng thư mục, fsync rồi rename đè file cũ,
+// nên khi crash file chỉ có thể là bản cũ hoặc bản mới hoàn chỉnh.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // không còn tác dụng sau khi rename thành công
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	// fsync thư mục để việc rename cũng được ghi xuống đĩa
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
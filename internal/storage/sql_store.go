@@ -0,0 +1,470 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // driver "sqlite", thuần Go
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// migrations được áp dụng theo thứ tự; PRAGMA user_version lưu số migration đã chạy.
+// Chỉ được thêm migration mới vào cuối, không sửa migration cũ.
+var migrations = []string{
+	// 1: players, towers, troop collections
+	`CREATE TABLE players (
+		username      TEXT PRIMARY KEY,
+		password      TEXT NOT NULL DEFAULT '',
+		password_hash TEXT NOT NULL DEFAULT '',
+		exp           INTEGER NOT NULL DEFAULT 0,
+		level         INTEGER NOT NULL DEFAULT 1,
+		mana          INTEGER NOT NULL DEFAULT 0,
+		crits_left    INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE player_towers (
+		username TEXT NOT NULL REFERENCES players(username) ON DELETE CASCADE,
+		slot     INTEGER NOT NULL,
+		type     TEXT NOT NULL,
+		hp       INTEGER NOT NULL,
+		atk      INTEGER NOT NULL,
+		def      INTEGER NOT NULL,
+		crit     REAL NOT NULL,
+		exp      INTEGER NOT NULL,
+		PRIMARY KEY (username, slot)
+	);
+	CREATE TABLE player_troops (
+		username TEXT NOT NULL REFERENCES players(username) ON DELETE CASCADE,
+		slot     INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		hp       INTEGER NOT NULL,
+		atk      INTEGER NOT NULL,
+		def      INTEGER NOT NULL,
+		mana     INTEGER NOT NULL,
+		exp      INTEGER NOT NULL,
+		special  TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (username, slot)
+	);`,
+	// 2: match history
+	`CREATE TABLE matches (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		player1    TEXT NOT NULL,
+		player2    TEXT NOT NULL,
+		mode       TEXT NOT NULL,
+		winner     TEXT NOT NULL DEFAULT '',
+		reason     TEXT NOT NULL DEFAULT '',
+		started_at TIMESTAMP NOT NULL,
+		ended_at   TIMESTAMP
+	);
+	CREATE INDEX matches_player1 ON matches(player1);
+	CREATE INDEX matches_player2 ON matches(player2);
+	CREATE TABLE match_events (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+		at       TIMESTAMP NOT NULL,
+		kind     TEXT NOT NULL,
+		player   TEXT NOT NULL DEFAULT '',
+		detail   TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX match_events_match ON match_events(match_id);`,
+	// 3: troop movement speed on the arena
+	`ALTER TABLE player_troops ADD COLUMN speed REAL NOT NULL DEFAULT 0;`,
+	// 4: ability parameters, stored as JSON
+	`ALTER TABLE player_troops ADD COLUMN params TEXT NOT NULL DEFAULT '';`,
+	// 5: card collections and saved decks
+	`CREATE TABLE player_cards (
+		username TEXT NOT NULL REFERENCES players(username) ON DELETE CASCADE,
+		name     TEXT NOT NULL,
+		copies   INTEGER NOT NULL,
+		PRIMARY KEY (username, name)
+	);
+	CREATE TABLE player_deck (
+		username TEXT NOT NULL REFERENCES players(username) ON DELETE CASCADE,
+		slot     INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		PRIMARY KEY (username, slot)
+	);`,
+	// 6: gold and card / tower levels
+	`ALTER TABLE players ADD COLUMN gold INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE player_levels (
+		username TEXT NOT NULL REFERENCES players(username) ON DELETE CASCADE,
+		kind     TEXT NOT NULL, -- 'card' hoặc 'tower'
+		name     TEXT NOT NULL,
+		level    INTEGER NOT NULL,
+		exp      INTEGER NOT NULL,
+		PRIMARY KEY (username, kind, name)
+	);`,
+	// 7: Elo rating
+	`ALTER TABLE players ADD COLUMN rating INTEGER NOT NULL DEFAULT 0;`,
+	// 8: seed RNG của mỗi trận
+	`ALTER TABLE matches ADD COLUMN seed INTEGER NOT NULL DEFAULT 0;`,
+}
+
+// SQLStore is a SQLite-backed PlayerStore that also keeps match history.
+// Unlike FileStore it does not hold every player in memory: Get always loads
+// a fresh copy, so changes must be written back with Put or Update.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore opens (or creates) the database at path and runs pending migrations.
+func NewSQLStore(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite chỉ cho một writer; một kết nối tránh lỗi "database is locked"
+	db.SetMaxOpenConns(1)
+
+	store := &SQLStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SQLStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA không nhận tham số ? nên phải format trực tiếp
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Printf("🗄️ Applied database migration %d\n", i+1)
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(username string) (*models.Player, bool) {
+	player, err := s.load(s.db, username)
+	if err != nil {
+		if err != ErrPlayerNotFound {
+			fmt.Printf("❌ Failed to load player %s: %v\n", username, err)
+		}
+		return nil, false
+	}
+	return player, true
+}
+
+// queryer là phần chung của *sql.DB và *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (s *SQLStore) load(q queryer, username string) (*models.Player, error) {
+	p := &models.Player{Username: username}
+	err := q.QueryRow(`SELECT password, password_hash, exp, level, mana, crits_left, gold, rating FROM players WHERE username = ?`, username).
+		Scan(&p.Password, &p.PasswordHash, &p.EXP, &p.Level, &p.Mana, &p.CritsLeft, &p.Gold, &p.Rating)
+	if err == sql.ErrNoRows {
+		return nil, ErrPlayerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`SELECT type, hp, atk, def, crit, exp FROM player_towers WHERE username = ? ORDER BY slot`, username)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t models.Tower
+		if err := rows.Scan(&t.Type, &t.HP, &t.ATK, &t.DEF, &t.CRIT, &t.EXP); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Towers = append(p.Towers, t)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`SELECT name, copies FROM player_cards WHERE username = ?`, username)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var copies int
+		if err := rows.Scan(&name, &copies); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if p.Collection == nil {
+			p.Collection = make(map[string]int)
+		}
+		p.Collection[name] = copies
+	}
+	rows.Close()
+
+	rows, err = q.Query(`SELECT name FROM player_deck WHERE username = ? ORDER BY slot`, username)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Deck = append(p.Deck, name)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`SELECT kind, name, level, exp FROM player_levels WHERE username = ?`, username)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var kind, name string
+		var prog models.Progress
+		if err := rows.Scan(&kind, &name, &prog.Level, &prog.EXP); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		levels := &p.CardLevels
+		if kind == "tower" {
+			levels = &p.TowerLevels
+		}
+		if *levels == nil {
+			*levels = make(map[string]models.Progress)
+		}
+		(*levels)[name] = prog
+	}
+	rows.Close()
+
+	rows, err = q.Query(`SELECT name, hp, atk, def, mana, exp, speed, special, params FROM player_troops WHERE username = ? ORDER BY slot`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	p.Troops = []models.Troop{}
+	for rows.Next() {
+		var t models.Troop
+		var params string
+		if err := rows.Scan(&t.Name, &t.HP, &t.ATK, &t.DEF, &t.Mana, &t.EXP, &t.Speed, &t.Special, &params); err != nil {
+			return nil, err
+		}
+		if params != "" {
+			if err := json.Unmarshal([]byte(params), &t.Params); err != nil {
+				return nil, err
+			}
+		}
+		p.Troops = append(p.Troops, t)
+	}
+	return p, rows.Err()
+}
+
+func (s *SQLStore) Put(player *models.Player) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := s.save(tx, player); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) save(tx *sql.Tx, p *models.Player) error {
+	_, err := tx.Exec(`INSERT INTO players (username, password, password_hash, exp, level, mana, crits_left, gold, rating)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET
+			password = excluded.password, password_hash = excluded.password_hash,
+			exp = excluded.exp, level = excluded.level, mana = excluded.mana, crits_left = excluded.crits_left,
+			gold = excluded.gold, rating = excluded.rating`,
+		p.Username, p.Password, p.PasswordHash, p.EXP, p.Level, p.Mana, p.CritsLeft, p.Gold, p.Rating)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM player_towers WHERE username = ?`, p.Username); err != nil {
+		return err
+	}
+	for i, t := range p.Towers {
+		if _, err := tx.Exec(`INSERT INTO player_towers (username, slot, type, hp, atk, def, crit, exp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.Username, i, t.Type, t.HP, t.ATK, t.DEF, t.CRIT, t.EXP); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM player_cards WHERE username = ?`, p.Username); err != nil {
+		return err
+	}
+	for name, copies := range p.Collection {
+		if _, err := tx.Exec(`INSERT INTO player_cards (username, name, copies) VALUES (?, ?, ?)`, p.Username, name, copies); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM player_deck WHERE username = ?`, p.Username); err != nil {
+		return err
+	}
+	for i, name := range p.Deck {
+		if _, err := tx.Exec(`INSERT INTO player_deck (username, slot, name) VALUES (?, ?, ?)`, p.Username, i, name); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM player_levels WHERE username = ?`, p.Username); err != nil {
+		return err
+	}
+	for kind, levels := range map[string]map[string]models.Progress{"card": p.CardLevels, "tower": p.TowerLevels} {
+		for name, prog := range levels {
+			if _, err := tx.Exec(`INSERT INTO player_levels (username, kind, name, level, exp) VALUES (?, ?, ?, ?, ?)`,
+				p.Username, kind, name, prog.Level, prog.EXP); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM player_troops WHERE username = ?`, p.Username); err != nil {
+		return err
+	}
+	for i, t := range p.Troops {
+		params := ""
+		if len(t.Params) > 0 {
+			data, err := json.Marshal(t.Params)
+			if err != nil {
+				return err
+			}
+			params = string(data)
+		}
+		if _, err := tx.Exec(`INSERT INTO player_troops (username, slot, name, hp, atk, def, mana, exp, speed, special, params) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.Username, i, t.Name, t.HP, t.ATK, t.DEF, t.Mana, t.EXP, t.Speed, t.Special, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) List() []*models.Player {
+	rows, err := s.db.Query(`SELECT username FROM players ORDER BY username`)
+	if err != nil {
+		fmt.Printf("❌ Failed to list players: %v\n", err)
+		return nil
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+
+	list := make([]*models.Player, 0, len(names))
+	for _, name := range names {
+		if p, ok := s.Get(name); ok {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func (s *SQLStore) Update(username string, fn func(*models.Player) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	player, err := s.load(tx, username)
+	if err == nil {
+		err = fn(player)
+	}
+	if err == nil {
+		err = s.save(tx, player)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Delete(username string) error {
+	res, err := s.db.Exec(`DELETE FROM players WHERE username = ?`, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPlayerNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// StartMatch records the beginning of a match and returns its id.
+func (s *SQLStore) StartMatch(player1, player2, mode string, seed int64) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO matches (player1, player2, mode, seed, started_at) VALUES (?, ?, ?, ?, ?)`,
+		player1, player2, mode, seed, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RecordEvents appends events to a match in a single transaction.
+func (s *SQLStore) RecordEvents(matchID int64, events []MatchEvent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		_, err = tx.Exec(`INSERT INTO match_events (match_id, at, kind, player, detail) VALUES (?, ?, ?, ?, ?)`,
+			matchID, e.At.UTC(), e.Kind, e.Player, e.Detail)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FinishMatch stores the result of a match. winner is empty for a draw.
+func (s *SQLStore) FinishMatch(matchID int64, winner, reason string) error {
+	_, err := s.db.Exec(`UPDATE matches SET winner = ?, reason = ?, ended_at = ? WHERE id = ?`,
+		winner, reason, time.Now().UTC(), matchID)
+	return err
+}
+
+// MatchHistory returns the most recent matches username took part in.
+func (s *SQLStore) MatchHistory(username string, limit int) ([]MatchSummary, error) {
+	rows, err := s.db.Query(`SELECT id, player1, player2, mode, seed, winner, reason, started_at, ended_at
+		FROM matches WHERE player1 = ? OR player2 = ? ORDER BY started_at DESC LIMIT ?`, username, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []MatchSummary
+	for rows.Next() {
+		var m MatchSummary
+		var ended sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Player1, &m.Player2, &m.Mode, &m.Seed, &m.Winner, &m.Reason, &m.StartedAt, &ended); err != nil {
+			return nil, err
+		}
+		m.EndedAt = ended.Time
+		history = append(history, m)
+	}
+	return history, rows.Err()
+}
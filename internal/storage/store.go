@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// ErrPlayerNotFound is returned when a username has no stored player.
+var ErrPlayerNotFound = errors.New("player not found")
+
+// PlayerStore persists player accounts and progress.
+//
+// Get and List return copies and Put stores a copy, so changes are only
+// visible to later reads after Put or Update.
+type PlayerStore interface {
+	Get(username string) (*models.Player, bool)
+	Put(player *models.Player) error
+	List() []*models.Player
+	// Update chạy fn trên player rồi lưu lại; fn trả lỗi thì không lưu.
+	Update(username string, fn func(*models.Player) error) error
+	Delete(username string) error
+	// Close ghi toàn bộ dữ liệu còn lại, gọi khi server tắt.
+	Close() error
+}
+
+// MatchRecorder is implemented by stores that keep match history.
+type MatchRecorder interface {
+	StartMatch(player1, player2, mode string, seed int64) (int64, error)
+	// RecordEvents lưu các sự kiện của trận trong một lần ghi.
+	RecordEvents(matchID int64, events []MatchEvent) error
+	FinishMatch(matchID int64, winner, reason string) error
+}
+
+// MatchEvent là một sự kiện (attack, heal, tower destroyed, ...) trong trận.
+type MatchEvent struct {
+	At     time.Time
+	Kind   string
+	Player string
+	Detail string // JSON
+}
+
+// MatchHistorian is implemented by stores that can list past matches.
+type MatchHistorian interface {
+	MatchHistory(username string, limit int) ([]MatchSummary, error)
+}
+
+// MatchSummary là một dòng trong lịch sử đấu.
+type MatchSummary struct {
+	ID        int64
+	Player1   string
+	Player2   string
+	Mode      string
+	Seed      int64 // seed RNG của trận, dùng để tái hiện trận đấu
+	Winner    string
+	Reason    string
+	StartedAt time.Time
+	EndedAt   time.Time
+}
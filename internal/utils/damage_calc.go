@@ -1,9 +1,10 @@
 package utils
 
-// CalculateDamage tính toán lượng damage gây ra dựa trên ATK, DEF và CRIT%
-func CalculateDamage(atk int, def int, useCrit bool) int {
+// CalculateDamage tính toán lượng damage gây ra dựa trên ATK, DEF và CRIT%;
+// đòn CRIT nhân ATK với critMultiplier.
+func CalculateDamage(atk int, def int, useCrit bool, critMultiplier float64) int {
 	if useCrit {
-		atk = int(float64(atk) * 1.2)
+		atk = int(float64(atk) * critMultiplier)
 	}
 	dmg := atk - def
 	if dmg < 0 {
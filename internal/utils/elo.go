@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"math"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+const (
+	// InitialRating là rating của người chơi chưa đấu trận nào.
+	InitialRating = 1000
+	// EloK là hệ số K: mức thay đổi rating tối đa sau một trận.
+	EloK = 32
+)
+
+// UpdateElo trả về rating mới của A và B sau một trận; scoreA là 1 nếu A
+// thắng, 0.5 nếu hoà và 0 nếu A thua.
+func UpdateElo(ratingA, ratingB int, scoreA float64) (int, int) {
+	expectedA := 1 / (1 + math.Pow(10, float64(ratingB-ratingA)/400))
+	delta := int(math.Round(EloK * (scoreA - expectedA)))
+	return ratingA + delta, ratingB - delta
+}
+
+// PlayerRating trả về rating của người chơi; tài khoản cũ chưa có rating
+// được tính là InitialRating.
+func PlayerRating(p *models.Player) int {
+	if p.Rating == 0 {
+		return InitialRating
+	}
+	return p.Rating
+}
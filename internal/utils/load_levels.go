@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// LoadLevelCurves reads card and tower level curves from path. Unknown keys
+// and invalid steps are errors.
+func LoadLevelCurves(path string) (models.LevelCurves, error) {
+	var curves models.LevelCurves
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return curves, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&curves); err != nil {
+		return curves, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if err := curves.Validate(); err != nil {
+		return curves, fmt.Errorf("invalid level curves %s: %w", path, err)
+	}
+	return curves, nil
+}
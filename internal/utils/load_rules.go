@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"net-centric-clash-royale/internal/models"
+)
+
+// LoadRuleset reads a ruleset from path. Rules missing from the file keep
+// their default value; unknown keys and invalid values are errors.
+func LoadRuleset(path string) (models.Ruleset, error) {
+	rules := models.DefaultRuleset()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return rules, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return rules, fmt.Errorf("invalid ruleset %s: %w", path, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash được dùng khi username không tồn tại để thời gian phản hồi không lộ thông tin.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+// HashPassword returns a salted bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares password with a bcrypt hash in constant time.
+// An empty hash still costs one bcrypt comparison.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// CheckLegacyPassword so sánh mật khẩu plaintext cũ (trước khi có hash) trong thời gian hằng.
+func CheckLegacyPassword(stored, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}